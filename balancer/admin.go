@@ -0,0 +1,24 @@
+package balancer
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminSnapshot 处理查询所有provider熔断器/健康分状态的Admin请求
+func AdminSnapshot(c *gin.Context) {
+	c.JSON(http.StatusOK, Default.Snapshot())
+}
+
+// AdminReset 处理重置单个provider熔断器状态的Admin请求
+func AdminReset(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid provider id"})
+		return
+	}
+	Default.Reset(uint(id))
+	c.JSON(http.StatusOK, gin.H{"reset": id})
+}
@@ -0,0 +1,31 @@
+package balancer
+
+import (
+	"errors"
+	"math/rand/v2"
+)
+
+// WeightedRandom 根据权重随机选择一个候选项，权重<=0的候选项会被忽略
+func WeightedRandom(items map[uint]int) (*uint, error) {
+	total := 0
+	for _, w := range items {
+		if w > 0 {
+			total += w
+		}
+	}
+	if total <= 0 {
+		return nil, errors.New("no available item to select")
+	}
+
+	r := rand.IntN(total)
+	for id, w := range items {
+		if w <= 0 {
+			continue
+		}
+		if r < w {
+			return &id, nil
+		}
+		r -= w
+	}
+	return nil, errors.New("failed to select item")
+}
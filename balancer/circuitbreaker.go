@@ -0,0 +1,288 @@
+package balancer
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// State 表示单个provider熔断器所处的状态
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig 熔断器的阈值配置
+type BreakerConfig struct {
+	FailureThreshold int           // 连续失败达到该次数后跳闸进入Open
+	CooldownDuration time.Duration // Open状态持续时间，之后转入HalfOpen
+	HalfOpenProbes   int           // HalfOpen状态下允许的并发探测请求数
+}
+
+// DefaultBreakerConfig 返回一组保守的默认阈值
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 5,
+		CooldownDuration: 30 * time.Second,
+		HalfOpenProbes:   1,
+	}
+}
+
+// ewmaAlpha 控制健康分的衰减/恢复速度，越大越敏感
+const ewmaAlpha = 0.2
+
+// latencyWindow 是参与p95估算的最近请求数
+const latencyWindow = 50
+
+// providerStats 维护单个provider的熔断器状态与滚动健康数据
+type providerStats struct {
+	mu sync.Mutex
+
+	cfg BreakerConfig
+
+	state         State
+	openedAt      time.Time
+	consecFails   int
+	halfOpenInUse int
+
+	// healthScore 是(0,1]区间的EWMA健康评分，成功时恢复，失败时衰减
+	healthScore float64
+	latencies   []time.Duration
+}
+
+func newProviderStats(cfg BreakerConfig) *providerStats {
+	return &providerStats{
+		cfg:         cfg,
+		state:       StateClosed,
+		healthScore: 1,
+	}
+}
+
+// allow 判断该provider是否可以出现在候选列表中；Open状态（冷却未过）直接排除，
+// HalfOpen状态仅在还有空闲探测名额时才算可候选。这里只读不消耗名额——
+// 真正的探测名额消耗发生在acquire，即候选被WeightedRandom选中、即将实际下发请求的那一刻
+func (p *providerStats) allow() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch p.state {
+	case StateOpen:
+		return time.Since(p.openedAt) >= p.cfg.CooldownDuration
+	case StateHalfOpen:
+		return p.halfOpenInUse < p.cfg.HalfOpenProbes
+	default:
+		return true
+	}
+}
+
+// acquire 在真正向该provider下发请求前获取一次执行许可；Open状态下冷却已过会转入HalfOpen
+// 并消耗一个探测名额，HalfOpen状态下名额耗尽则拒绝。只有被WeightedRandom选中的候选才会
+// 调用到这里，因此未被选中的HalfOpen候选不会白白占用仅有的探测名额
+func (p *providerStats) acquire() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch p.state {
+	case StateOpen:
+		if time.Since(p.openedAt) < p.cfg.CooldownDuration {
+			return false
+		}
+		p.state = StateHalfOpen
+		p.halfOpenInUse = 0
+		fallthrough
+	case StateHalfOpen:
+		if p.halfOpenInUse >= p.cfg.HalfOpenProbes {
+			return false
+		}
+		p.halfOpenInUse++
+		return true
+	default:
+		return true
+	}
+}
+
+// releaseProbe 归还一次acquire消耗的HalfOpen探测名额，但不改变状态、不影响健康分；
+// 用于一次探测请求虽然拿到了许可，但既不能算成功也不该算失败的场景(如被限流、
+// 或在对冲竞速里刚拿到许可就落败/被取消)——这些场景如果什么都不做，名额就会
+// 永久泄漏，provider再也没有机会进入下一次HalfOpen探测
+func (p *providerStats) releaseProbe() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state == StateHalfOpen && p.halfOpenInUse > 0 {
+		p.halfOpenInUse--
+	}
+}
+
+// recordSuccess 恢复健康分，并在HalfOpen探测成功后重新闭合熔断器
+func (p *providerStats) recordSuccess(latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.consecFails = 0
+	p.healthScore += ewmaAlpha * (1 - p.healthScore)
+	p.latencies = append(p.latencies, latency)
+	if len(p.latencies) > latencyWindow {
+		p.latencies = p.latencies[len(p.latencies)-latencyWindow:]
+	}
+
+	if p.state == StateHalfOpen {
+		p.state = StateClosed
+		p.halfOpenInUse = 0
+	}
+}
+
+// recordFailure 衰减健康分，并在达到失败阈值（或HalfOpen探测失败）时跳闸
+func (p *providerStats) recordFailure() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.consecFails++
+	p.healthScore -= ewmaAlpha * p.healthScore
+
+	if p.state == StateHalfOpen {
+		p.state = StateOpen
+		p.openedAt = time.Now()
+		p.halfOpenInUse = 0
+		return
+	}
+
+	if p.consecFails >= p.cfg.FailureThreshold {
+		p.state = StateOpen
+		p.openedAt = time.Now()
+	}
+}
+
+func (p *providerStats) snapshot() Snapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p95 := time.Duration(0)
+	if len(p.latencies) > 0 {
+		sorted := slices.Clone(p.latencies)
+		slices.Sort(sorted)
+		idx := min(int(float64(len(sorted))*0.95), len(sorted)-1)
+		p95 = sorted[idx]
+	}
+
+	return Snapshot{
+		State:       p.state.String(),
+		HealthScore: p.healthScore,
+		ConsecFails: p.consecFails,
+		P95Latency:  p95,
+	}
+}
+
+// Snapshot 是单个provider健康状态的只读视图，供Admin API展示
+type Snapshot struct {
+	State       string        `json:"state"`
+	HealthScore float64       `json:"health_score"`
+	ConsecFails int           `json:"consec_fails"`
+	P95Latency  time.Duration `json:"p95_latency"`
+}
+
+// Registry 跨请求维护所有provider的熔断器与健康分状态
+type Registry struct {
+	mu    sync.Mutex
+	cfg   BreakerConfig
+	stats map[uint]*providerStats
+}
+
+// NewRegistry 创建一个使用给定阈值配置的Registry
+func NewRegistry(cfg BreakerConfig) *Registry {
+	return &Registry{
+		cfg:   cfg,
+		stats: make(map[uint]*providerStats),
+	}
+}
+
+// Default 是进程级全局熔断器注册表，BalanceChat在请求间共享同一份健康状态
+var Default = NewRegistry(DefaultBreakerConfig())
+
+func (r *Registry) get(providerID uint) *providerStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.stats[providerID]
+	if !ok {
+		s = newProviderStats(r.cfg)
+		r.stats[providerID] = s
+	}
+	return s
+}
+
+// Allow 判断providerID当前是否允许出现在本轮负载均衡的候选列表中；
+// 这是一次只读检查，不会消耗HalfOpen的探测名额，真正的许可在Acquire中获取
+func (r *Registry) Allow(providerID uint) bool {
+	return r.get(providerID).allow()
+}
+
+// Acquire 在真正向providerID下发请求前获取一次执行许可；HalfOpen状态下仅有限个探测请求
+// 能获取成功，未被WeightedRandom选中的候选不会消耗这个名额
+func (r *Registry) Acquire(providerID uint) bool {
+	return r.get(providerID).acquire()
+}
+
+// ReleaseProbe 归还providerID之前通过Acquire获取、但既不算成功也不算失败的
+// HalfOpen探测名额，避免名额被占用后再也没有机会释放
+func (r *Registry) ReleaseProbe(providerID uint) {
+	r.get(providerID).releaseProbe()
+}
+
+// RecordSuccess 记录providerID的一次成功调用及其耗时
+func (r *Registry) RecordSuccess(providerID uint, latency time.Duration) {
+	r.get(providerID).recordSuccess(latency)
+}
+
+// RecordFailure 记录providerID的一次失败调用
+func (r *Registry) RecordFailure(providerID uint) {
+	r.get(providerID).recordFailure()
+}
+
+// EffectiveWeight 用健康分折算配置权重，供WeightedRandom使用；
+// 健康分越低，被选中的概率越低，但未跳闸的provider始终保留被选中的机会
+func (r *Registry) EffectiveWeight(providerID uint, configuredWeight int) int {
+	s := r.get(providerID)
+	s.mu.Lock()
+	score := s.healthScore
+	s.mu.Unlock()
+
+	weight := int(float64(configuredWeight) * score)
+	if weight <= 0 && configuredWeight > 0 {
+		weight = 1
+	}
+	return weight
+}
+
+// Snapshot 返回所有已跟踪provider当前的健康状态，供Admin API查询
+func (r *Registry) Snapshot() map[uint]Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[uint]Snapshot, len(r.stats))
+	for id, s := range r.stats {
+		out[id] = s.snapshot()
+	}
+	return out
+}
+
+// Reset 清除providerID的熔断器状态，使其重新从Closed+满分健康开始
+func (r *Registry) Reset(providerID uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.stats, providerID)
+}
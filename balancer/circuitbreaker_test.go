@@ -0,0 +1,134 @@
+package balancer
+
+import (
+	"testing"
+	"time"
+)
+
+func testConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 3,
+		CooldownDuration: 20 * time.Millisecond,
+		HalfOpenProbes:   1,
+	}
+}
+
+func TestProviderStatsOpensAfterThreshold(t *testing.T) {
+	p := newProviderStats(testConfig())
+
+	for i := 0; i < testConfig().FailureThreshold-1; i++ {
+		p.recordFailure()
+		if p.state != StateClosed {
+			t.Fatalf("expected still closed after %d failures, got %s", i+1, p.state)
+		}
+	}
+
+	p.recordFailure()
+	if p.state != StateOpen {
+		t.Fatalf("expected open after reaching failure threshold, got %s", p.state)
+	}
+	if p.allow() {
+		t.Fatal("expected open provider to be disallowed before cooldown elapses")
+	}
+}
+
+func TestProviderStatsHalfOpenAfterCooldown(t *testing.T) {
+	cfg := testConfig()
+	p := newProviderStats(cfg)
+	p.state = StateOpen
+	p.openedAt = time.Now().Add(-2 * cfg.CooldownDuration)
+
+	if !p.allow() {
+		t.Fatal("expected provider to be allowed once cooldown elapsed")
+	}
+	if !p.acquire() {
+		t.Fatal("expected first probe to acquire a permit")
+	}
+	if p.state != StateHalfOpen {
+		t.Fatalf("expected half_open after acquiring the probe permit, got %s", p.state)
+	}
+}
+
+func TestProviderStatsHalfOpenProbeLimit(t *testing.T) {
+	cfg := testConfig()
+	cfg.HalfOpenProbes = 1
+	p := newProviderStats(cfg)
+	p.state = StateHalfOpen
+
+	if !p.acquire() {
+		t.Fatal("expected first probe to acquire the only permit")
+	}
+	if p.acquire() {
+		t.Fatal("expected second concurrent probe to be rejected while the first is in flight")
+	}
+	if p.allow() {
+		t.Fatal("expected Allow to also report no candidate slot while the probe permit is held")
+	}
+}
+
+func TestProviderStatsRecordSuccessClosesHalfOpen(t *testing.T) {
+	p := newProviderStats(testConfig())
+	p.state = StateHalfOpen
+	p.halfOpenInUse = 1
+
+	p.recordSuccess(10 * time.Millisecond)
+
+	if p.state != StateClosed {
+		t.Fatalf("expected closed after a successful half_open probe, got %s", p.state)
+	}
+	if p.halfOpenInUse != 0 {
+		t.Fatalf("expected probe permit to be released, got halfOpenInUse=%d", p.halfOpenInUse)
+	}
+}
+
+func TestProviderStatsRecordFailureReopensHalfOpen(t *testing.T) {
+	p := newProviderStats(testConfig())
+	p.state = StateHalfOpen
+	p.halfOpenInUse = 1
+
+	p.recordFailure()
+
+	if p.state != StateOpen {
+		t.Fatalf("expected open after a failed half_open probe, got %s", p.state)
+	}
+	if p.halfOpenInUse != 0 {
+		t.Fatalf("expected probe permit to be released, got halfOpenInUse=%d", p.halfOpenInUse)
+	}
+}
+
+func TestProviderStatsReleaseProbeWithoutSideEffects(t *testing.T) {
+	p := newProviderStats(testConfig())
+	p.state = StateHalfOpen
+	p.halfOpenInUse = 1
+
+	p.releaseProbe()
+
+	if p.state != StateHalfOpen {
+		t.Fatalf("expected releaseProbe to leave state untouched, got %s", p.state)
+	}
+	if p.halfOpenInUse != 0 {
+		t.Fatalf("expected probe permit to be released, got halfOpenInUse=%d", p.halfOpenInUse)
+	}
+	if p.consecFails != 0 {
+		t.Fatalf("expected releaseProbe not to affect consecFails, got %d", p.consecFails)
+	}
+}
+
+func TestRegistryAcquireReleasesOnlyViaRecordOrRelease(t *testing.T) {
+	r := NewRegistry(testConfig())
+	const providerID = uint(1)
+
+	r.get(providerID).state = StateHalfOpen
+
+	if !r.Acquire(providerID) {
+		t.Fatal("expected first acquire to succeed")
+	}
+	if r.Acquire(providerID) {
+		t.Fatal("expected second acquire to fail while the only probe permit is in use")
+	}
+
+	r.ReleaseProbe(providerID)
+	if !r.Acquire(providerID) {
+		t.Fatal("expected acquire to succeed again after ReleaseProbe")
+	}
+}
@@ -0,0 +1,91 @@
+package balancer
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/atopos31/llmio/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// stateFromString 把持久化的状态字符串还原为State；无法识别时保守地当作Closed处理
+func stateFromString(s string) State {
+	switch s {
+	case StateOpen.String():
+		return StateOpen
+	case StateHalfOpen.String():
+		return StateHalfOpen
+	default:
+		return StateClosed
+	}
+}
+
+// seed 用一份持久化快照初始化providerID的内存状态，仅在RestoreFromDB启动时调用；
+// HalfOpen会被还原为Closed——探测名额本身不具备跨重启的意义，保留已读到的健康分更有用
+func (r *Registry) seed(providerID uint, state State, healthScore float64, consecFails int) {
+	s := r.get(providerID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.healthScore = healthScore
+	s.consecFails = consecFails
+	if state == StateOpen {
+		s.state = StateOpen
+		s.openedAt = time.Now()
+	}
+}
+
+// SnapshotToDB 把当前已跟踪的provider健康状态落盘，供短暂重启窗口后恢复；
+// 调用方（进程启动时）应搭配RestoreFromDB，并按需用StartPeriodicSnapshot定期触发本函数
+func (r *Registry) SnapshotToDB(ctx context.Context) error {
+	for providerID, snap := range r.Snapshot() {
+		row := models.ProviderHealth{
+			ProviderID:  providerID,
+			State:       snap.State,
+			HealthScore: snap.HealthScore,
+			ConsecFails: snap.ConsecFails,
+			UpdatedAt:   time.Now(),
+		}
+		err := gorm.G[models.ProviderHealth](models.DB, clause.OnConflict{
+			Columns:   []clause.Column{{Name: "provider_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"state", "health_score", "consec_fails", "updated_at"}),
+		}).Create(ctx, &row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreFromDB 从上一次快照恢复provider健康状态；应在进程启动、首个请求到来之前调用一次
+func (r *Registry) RestoreFromDB(ctx context.Context) error {
+	rows, err := gorm.G[models.ProviderHealth](models.DB).Find(ctx)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		r.seed(row.ProviderID, stateFromString(row.State), row.HealthScore, row.ConsecFails)
+	}
+	return nil
+}
+
+// StartPeriodicSnapshot 启动一个后台goroutine，按interval周期性调用SnapshotToDB，
+// 直到ctx被取消；由进程的启动入口决定是否启用落盘
+func (r *Registry) StartPeriodicSnapshot(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.SnapshotToDB(ctx); err != nil {
+					slog.Error("provider_health_snapshot_failed", "error", err)
+				}
+			}
+		}
+	}()
+}
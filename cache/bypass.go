@@ -0,0 +1,19 @@
+package cache
+
+import "encoding/json"
+
+// shouldBypass 判断本次请求是否绕过缓存：
+// 显式设置了扩展字段"cache": false，或请求携带了tools（工具调用结果依赖运行时上下文，不适合缓存）
+func shouldBypass(raw []byte) bool {
+	var probe struct {
+		Cache *bool             `json:"cache"`
+		Tools []json.RawMessage `json:"tools"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return false
+	}
+	if probe.Cache != nil && !*probe.Cache {
+		return true
+	}
+	return len(probe.Tools) > 0
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// 缓存查询结果，写入X-Cache响应头并驱动命中率指标
+const (
+	HitExact    = "HIT"
+	HitSemantic = "SEMANTIC_HIT"
+	Miss        = "MISS"
+	Bypass      = "BYPASS"
+)
+
+// defaultMaxEntries和defaultTTL是默认的精确匹配缓存容量与存活时间
+const (
+	defaultMaxEntries = 1000
+	defaultTTL        = 10 * time.Minute
+)
+
+// semanticThreshold是语义缓存命中所需的最低余弦相似度
+const semanticThreshold = 0.92
+
+var (
+	defaultIndex = NewFlatIndex()
+	// defaultStore淘汰一个key时同步从defaultIndex摘除对应向量，
+	// 否则语义缓存的flat scan会无限增长，绕开LRU容量上限的约束
+	defaultStore = NewLRUStore(defaultMaxEntries, defaultTTL, defaultIndex.Remove)
+)
+
+// Lookup 依次尝试精确匹配缓存与语义缓存；显式要求绕过缓存的请求直接返回Bypass。
+// apiKeyID原样作为命中率指标的api_key标签值，用于区分调用方
+func Lookup(ctx context.Context, raw []byte, apiKeyID string) (Entry, string) {
+	if shouldBypass(raw) {
+		recordResult(Bypass, apiKeyID)
+		return Entry{}, Bypass
+	}
+
+	key, err := exactKey(raw)
+	if err != nil {
+		slog.Warn("cache_key_error", "error", err)
+		recordResult(Miss, apiKeyID)
+		return Entry{}, Miss
+	}
+
+	if entry, ok := defaultStore.Get(key); ok {
+		recordResult(HitExact, apiKeyID)
+		return entry, HitExact
+	}
+
+	if DefaultEmbeddingProvider != nil {
+		if lastMessage, err := lastUserMessage(raw); err == nil && lastMessage != "" {
+			if embedding, err := DefaultEmbeddingProvider.Embed(ctx, lastMessage); err == nil {
+				if hitKey, ok := defaultIndex.Nearest(embedding, semanticThreshold); ok {
+					if entry, ok := defaultStore.Get(hitKey); ok {
+						recordResult(HitSemantic, apiKeyID)
+						return entry, HitSemantic
+					}
+				}
+			}
+		}
+	}
+
+	recordResult(Miss, apiKeyID)
+	return Entry{}, Miss
+}
+
+// Store 把一次上游响应写入精确匹配缓存，并在配置了EmbeddingProvider时一并写入语义索引
+func Store(ctx context.Context, style string, raw []byte, body []byte, stream bool) {
+	if shouldBypass(raw) {
+		return
+	}
+
+	key, err := exactKey(raw)
+	if err != nil {
+		slog.Warn("cache_key_error", "error", err)
+		return
+	}
+
+	usage := extractUsage(style, body)
+	defaultStore.Set(key, Entry{
+		Body:      body,
+		Stream:    stream,
+		TokensIn:  usage.PromptTokens,
+		TokensOut: usage.CompletionTokens,
+		CreatedAt: time.Now(),
+	})
+
+	if DefaultEmbeddingProvider == nil {
+		return
+	}
+	lastMessage, err := lastUserMessage(raw)
+	if err != nil || lastMessage == "" {
+		return
+	}
+	embedding, err := DefaultEmbeddingProvider.Embed(ctx, lastMessage)
+	if err != nil {
+		slog.Warn("cache_embedding_error", "error", err)
+		return
+	}
+	defaultIndex.Add(key, embedding)
+}
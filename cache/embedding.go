@@ -0,0 +1,12 @@
+package cache
+
+import "context"
+
+// EmbeddingProvider 计算一段文本的向量表示，用于语义缓存的相似度检索
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// DefaultEmbeddingProvider 供语义缓存查询用的embedding实现；
+// 未配置时语义缓存自动跳过，只做精确匹配
+var DefaultEmbeddingProvider EmbeddingProvider
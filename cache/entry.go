@@ -0,0 +1,12 @@
+package cache
+
+import "time"
+
+// Entry 是一条缓存的上游响应，保存原始响应体供回放，以及token计数供展示/复用
+type Entry struct {
+	Body      []byte
+	Stream    bool
+	TokensIn  int64
+	TokensOut int64
+	CreatedAt time.Time
+}
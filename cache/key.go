@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// keyFields 是参与精确匹配缓存key计算的请求字段
+type keyFields struct {
+	Model       string            `json:"model"`
+	Messages    []json.RawMessage `json:"messages"`
+	Tools       []json.RawMessage `json:"tools,omitempty"`
+	Temperature *float64          `json:"temperature,omitempty"`
+}
+
+// exactKey 对(model, messages, tools, temperature)做规范化哈希，作为精确匹配缓存的key
+func exactKey(raw []byte) (string, error) {
+	var fields keyFields
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return "", err
+	}
+	normalized, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(normalized)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lastUserMessage 提取请求体中最后一条user消息的文本内容，用于语义缓存的embedding输入
+func lastUserMessage(raw []byte) (string, error) {
+	var req struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return "", err
+	}
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return req.Messages[i].Content, nil
+		}
+	}
+	return "", nil
+}
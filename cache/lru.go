@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Store 是精确匹配缓存的存储接口：按key存取Entry，支持TTL与最大容量下的LRU淘汰
+type Store interface {
+	Get(key string) (Entry, bool)
+	Set(key string, entry Entry)
+}
+
+type lruItem struct {
+	key     string
+	entry   Entry
+	expires time.Time
+}
+
+// LRUStore 是进程内基于TTL+容量上限的LRU缓存实现
+type LRUStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxSize int
+	onEvict func(key string)
+	items   map[string]*list.Element
+	order   *list.List
+}
+
+// NewLRUStore 创建一个最多保存maxSize条、每条存活ttl时长的缓存；maxSize<=0表示不限容量。
+// onEvict在一个key因TTL过期或超出maxSize被淘汰时调用，可以为nil；
+// 用于让依赖这份缓存的周边索引（如语义缓存的向量索引）保持同步，不随之无限增长
+func NewLRUStore(maxSize int, ttl time.Duration, onEvict func(key string)) *LRUStore {
+	return &LRUStore{
+		ttl:     ttl,
+		maxSize: maxSize,
+		onEvict: onEvict,
+		items:   make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (s *LRUStore) Get(key string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	item := el.Value.(*lruItem)
+	if time.Now().After(item.expires) {
+		s.order.Remove(el)
+		delete(s.items, key)
+		if s.onEvict != nil {
+			s.onEvict(key)
+		}
+		return Entry{}, false
+	}
+	s.order.MoveToFront(el)
+	return item.entry, true
+}
+
+func (s *LRUStore) Set(key string, entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		item := el.Value.(*lruItem)
+		item.entry = entry
+		item.expires = time.Now().Add(s.ttl)
+		s.order.MoveToFront(el)
+		return
+	}
+
+	el := s.order.PushFront(&lruItem{key: key, entry: entry, expires: time.Now().Add(s.ttl)})
+	s.items[key] = el
+
+	for s.maxSize > 0 && s.order.Len() > s.maxSize {
+		oldest := s.order.Back()
+		if oldest == nil {
+			break
+		}
+		oldestKey := oldest.Value.(*lruItem).key
+		s.order.Remove(oldest)
+		delete(s.items, oldestKey)
+		if s.onEvict != nil {
+			s.onEvict(oldestKey)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var cacheResultTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "llmio_cache_result_total",
+	Help: "Cache lookup outcomes (HIT/SEMANTIC_HIT/MISS/BYPASS), labelled by result and API key.",
+}, []string{"result", "api_key"})
+
+func recordResult(status string, apiKeyID string) {
+	cacheResultTotal.WithLabelValues(status, apiKeyID).Inc()
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chunkSize和chunkPace近似模拟逐token输出的节奏，
+// 避免回放过快导致客户端体验与真实流式响应有明显差异
+const (
+	chunkSize = 32
+	chunkPace = 20 * time.Millisecond
+)
+
+// Replay 把缓存的响应体写回客户端；流式场景下按chunkSize切片并按chunkPace节奏发送
+func Replay(c *gin.Context, entry Entry) {
+	if entry.Stream {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+	} else {
+		c.Header("Content-Type", "application/json")
+	}
+	c.Status(http.StatusOK)
+
+	if !entry.Stream {
+		c.Writer.Write(entry.Body)
+		return
+	}
+
+	for i := 0; i < len(entry.Body); i += chunkSize {
+		end := min(i+chunkSize, len(entry.Body))
+		c.Writer.Write(entry.Body[i:end])
+		c.Writer.Flush()
+		time.Sleep(chunkPace)
+	}
+}
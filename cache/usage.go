@@ -0,0 +1,18 @@
+package cache
+
+import (
+	"bytes"
+
+	"github.com/atopos31/llmio/providers"
+)
+
+// extractUsage 尽力而为地从完整响应体中提取token用量，用于缓存条目的统计展示
+func extractUsage(style string, body []byte) providers.Usage {
+	var usage providers.Usage
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if u, ok := providers.ParseUsage(style, line); ok {
+			usage = u
+		}
+	}
+	return usage
+}
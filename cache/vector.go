@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"math"
+	"slices"
+	"sync"
+)
+
+// VectorIndex 是语义缓存使用的向量检索接口；flat scan是默认实现，
+// 未来可以替换为HNSW或pgvector等后端而不影响调用方
+type VectorIndex interface {
+	Add(key string, embedding []float32)
+	Nearest(embedding []float32, threshold float64) (key string, ok bool)
+	// Remove 剔除一个key对应的向量，在其精确匹配缓存条目被淘汰/过期时调用，
+	// 避免向量索引无限增长而突破"max size (LRU eviction)"的容量约束
+	Remove(key string)
+}
+
+type flatEntry struct {
+	key       string
+	embedding []float32
+}
+
+// FlatIndex 是对全部向量做线性扫描求余弦相似度的朴素实现，适合起步阶段的缓存规模
+type FlatIndex struct {
+	mu      sync.Mutex
+	entries []flatEntry
+}
+
+// NewFlatIndex 创建一个空的flat scan向量索引
+func NewFlatIndex() *FlatIndex {
+	return &FlatIndex{}
+}
+
+func (f *FlatIndex) Add(key string, embedding []float32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries = append(f.entries, flatEntry{key: key, embedding: embedding})
+}
+
+func (f *FlatIndex) Remove(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, e := range f.entries {
+		if e.key == key {
+			f.entries = slices.Delete(f.entries, i, i+1)
+			return
+		}
+	}
+}
+
+func (f *FlatIndex) Nearest(embedding []float32, threshold float64) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bestKey := ""
+	bestScore := -1.0
+	for _, e := range f.entries {
+		score := cosineSimilarity(embedding, e.embedding)
+		if score > bestScore {
+			bestScore = score
+			bestKey = e.key
+		}
+	}
+	if bestScore < threshold {
+		return "", false
+	}
+	return bestKey, true
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return -1
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return -1
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,125 @@
+package metering
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/atopos31/llmio/models"
+	"github.com/atopos31/llmio/providers"
+	"github.com/atopos31/llmio/ratelimit"
+)
+
+// RequestInfo 携带一次请求的身份与时序信息，在流结束后用于写入UsageRecord
+// 并对TPM/每日token配额做事后核销
+type RequestInfo struct {
+	Style           string
+	Model           string
+	ProviderName    string
+	ProviderModel   string
+	ChatLogID       uint
+	APIKeyID        uint
+	TPMLimit        int
+	DailyTokenLimit int64
+	Start           time.Time
+}
+
+// estimatedBytesPerToken 是没有上游usage字段时的粗略token估算系数
+const estimatedBytesPerToken = 4
+
+// Meter 消费一份与processer共享的响应数据流，增量解析token用量，
+// 并在流结束后计算费用写入UsageRecord；解析不到usage时退化为按字节数估算。
+func Meter(ctx context.Context, info RequestInfo, r io.Reader) {
+	reader := bufio.NewReaderSize(r, 32*1024)
+	var usage providers.Usage
+	var firstByteAt time.Time
+	var totalBytes int64
+
+	for {
+		chunk, err := reader.ReadBytes('\n')
+		if len(chunk) > 0 {
+			if firstByteAt.IsZero() {
+				firstByteAt = time.Now()
+			}
+			totalBytes += int64(len(chunk))
+			if u, ok := providers.ParseUsage(info.Style, chunk); ok {
+				usage = u
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+		// 上游没有给出usage，退化为按字节数粗略估算
+		usage = providers.Usage{CompletionTokens: totalBytes / estimatedBytesPerToken}
+	}
+
+	cost, err := Cost(ctx, info.ProviderName, info.ProviderModel, usage.PromptTokens, usage.CompletionTokens)
+	if err != nil {
+		slog.Warn("metering_price_lookup_failed",
+			"provider", info.ProviderName,
+			"model", info.ProviderModel,
+			"error", err,
+		)
+	}
+
+	elapsed := time.Since(info.Start)
+	ttfb := time.Duration(0)
+	if !firstByteAt.IsZero() {
+		ttfb = firstByteAt.Sub(info.Start)
+	}
+	tps := 0.0
+	if elapsed > 0 {
+		tps = float64(usage.CompletionTokens) / elapsed.Seconds()
+	}
+
+	record := models.UsageRecord{
+		ChatLogID:           info.ChatLogID,
+		APIKeyID:            info.APIKeyID,
+		Model:               info.Model,
+		ProviderName:        info.ProviderName,
+		Style:               info.Style,
+		TokensIn:            usage.PromptTokens,
+		TokensOut:           usage.CompletionTokens,
+		CostUsd:             cost,
+		FirstTokenLatencyMs: ttfb.Milliseconds(),
+		TokensPerSecond:     tps,
+	}
+
+	if err := Save(ctx, record); err != nil {
+		slog.Error("metering_save_failed", "error", err)
+		return
+	}
+
+	ratelimit.CommitTokenUsage(ctx, info.APIKeyID, info.TPMLimit, info.DailyTokenLimit, usage.PromptTokens+usage.CompletionTokens)
+
+	observe(record)
+}
+
+// RecordCacheHit 为一次缓存命中的请求补一条UsageRecord：没有真正调用上游，费用记为0，
+// 但token计数是缓存写入时就已经算好的真实值，必须照样核销TPM/每日token配额——
+// 否则缓存命中对ChatLog/admin用量聚合不可见，还会让一个key绕开配额无限消费缓存内容
+func RecordCacheHit(ctx context.Context, info RequestInfo, tokensIn, tokensOut int64) {
+	record := models.UsageRecord{
+		ChatLogID:    info.ChatLogID,
+		APIKeyID:     info.APIKeyID,
+		Model:        info.Model,
+		ProviderName: info.ProviderName,
+		Style:        info.Style,
+		TokensIn:     tokensIn,
+		TokensOut:    tokensOut,
+	}
+
+	if err := Save(ctx, record); err != nil {
+		slog.Error("metering_save_failed", "error", err)
+		return
+	}
+
+	ratelimit.CommitTokenUsage(ctx, info.APIKeyID, info.TPMLimit, info.DailyTokenLimit, tokensIn+tokensOut)
+
+	observe(record)
+}
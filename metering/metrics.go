@@ -0,0 +1,34 @@
+package metering
+
+import (
+	"github.com/atopos31/llmio/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	tokensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llmio_tokens_total",
+		Help: "Total number of tokens processed, labelled by direction/model/provider/style.",
+	}, []string{"direction", "model", "provider", "style"})
+
+	costTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llmio_cost_usd_total",
+		Help: "Total estimated cost in USD, labelled by model/provider/style.",
+	}, []string{"model", "provider", "style"})
+
+	ttfbSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llmio_ttfb_seconds",
+		Help:    "Time to first byte of the upstream response, labelled by model/provider/style.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"model", "provider", "style"})
+)
+
+// observe 将一条用量记录反映到Prometheus指标中
+func observe(record models.UsageRecord) {
+	labels := []string{record.Model, record.ProviderName, record.Style}
+	tokensTotal.WithLabelValues("in", record.Model, record.ProviderName, record.Style).Add(float64(record.TokensIn))
+	tokensTotal.WithLabelValues("out", record.Model, record.ProviderName, record.Style).Add(float64(record.TokensOut))
+	costTotal.WithLabelValues(labels...).Add(record.CostUsd)
+	ttfbSeconds.WithLabelValues(labels...).Observe(float64(record.FirstTokenLatencyMs) / 1000)
+}
@@ -0,0 +1,25 @@
+package metering
+
+import (
+	"context"
+	"errors"
+
+	"github.com/atopos31/llmio/models"
+	"gorm.io/gorm"
+)
+
+// Cost 按provider+model的单价表计算本次请求的费用(USD)；
+// 没有配置单价时视为免费，返回0而不是报错，避免计量失败影响主流程
+func Cost(ctx context.Context, providerName, model string, tokensIn, tokensOut int64) (float64, error) {
+	price, err := gorm.G[models.ModelPrice](models.DB).
+		Where("provider_name = ? AND model = ?", providerName, model).
+		First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	cost := float64(tokensIn)/1000*price.PromptPricePer1K + float64(tokensOut)/1000*price.CompletionPricePer1K
+	return cost, nil
+}
@@ -0,0 +1,48 @@
+package metering
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/atopos31/llmio/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Aggregate 是按API Key+模型聚合后的用量统计
+type Aggregate struct {
+	APIKeyID  uint    `json:"api_key_id"`
+	Model     string  `json:"model"`
+	TokensIn  int64   `json:"tokens_in"`
+	TokensOut int64   `json:"tokens_out"`
+	CostUsd   float64 `json:"cost_usd"`
+	Requests  int64   `json:"requests"`
+}
+
+// QueryAggregates 处理 GET /admin/usage?from=&to=，
+// 按API Key + 模型汇总给定时间范围内的token与费用
+func QueryAggregates(c *gin.Context) {
+	from, err := time.Parse(time.RFC3339, c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	to, err := time.Parse(time.RFC3339, c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+
+	var aggregates []Aggregate
+	err = gorm.G[models.UsageRecord](models.DB).
+		Where("created_at BETWEEN ? AND ?", from, to).
+		Select("api_key_id, model, sum(tokens_in) as tokens_in, sum(tokens_out) as tokens_out, sum(cost_usd) as cost_usd, count(*) as requests").
+		Group("api_key_id, model").
+		Scan(c.Request.Context(), &aggregates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, aggregates)
+}
@@ -0,0 +1,13 @@
+package metering
+
+import (
+	"context"
+
+	"github.com/atopos31/llmio/models"
+	"gorm.io/gorm"
+)
+
+// Save 持久化一条用量记录
+func Save(ctx context.Context, record models.UsageRecord) error {
+	return gorm.G[models.UsageRecord](models.DB).Create(ctx, &record)
+}
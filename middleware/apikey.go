@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/atopos31/llmio/common"
+	"github.com/atopos31/llmio/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 上下文键：鉴权通过后写入，供RateLimit()与计量子系统读取
+const (
+	AllowedModelsKey   = "allowed_models"
+	RPMLimitKey        = "rpm_limit"
+	TPMLimitKey        = "tpm_limit"
+	DailyTokenLimitKey = "daily_token_limit"
+)
+
+// hashToken 对原始token做不可逆哈希，数据库中只保存哈希值
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuthAPIKey 基于models.APIKey表进行鉴权，取代单一静态token；
+// 鉴权通过后在上下文中写入API Key标识、允许的模型列表及配额，供RateLimit()及计量使用
+func AuthAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := GetRequestID(c)
+
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			slog.Warn("apikey_auth_failed_missing_header",
+				"request_id", requestID,
+				"client_ip", c.ClientIP(),
+			)
+			common.ErrorWithHttpStatus(c, http.StatusUnauthorized, http.StatusUnauthorized, "Authorization header is missing")
+			c.Abort()
+			return
+		}
+
+		parts := strings.SplitN(authHeader, " ", 2)
+		if !(len(parts) == 2 && parts[0] == "Bearer") {
+			slog.Warn("apikey_auth_failed_invalid_format",
+				"request_id", requestID,
+				"client_ip", c.ClientIP(),
+			)
+			common.ErrorWithHttpStatus(c, http.StatusUnauthorized, http.StatusUnauthorized, "Invalid authorization header")
+			c.Abort()
+			return
+		}
+
+		key, err := gorm.G[models.APIKey](models.DB).Where("hashed_key = ?", hashToken(parts[1])).First(c.Request.Context())
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				slog.Warn("apikey_auth_failed_invalid_token",
+					"request_id", requestID,
+					"client_ip", c.ClientIP(),
+				)
+				common.ErrorWithHttpStatus(c, http.StatusUnauthorized, http.StatusUnauthorized, "Invalid token")
+			} else {
+				slog.Error("apikey_lookup_error",
+					"request_id", requestID,
+					"error", err,
+				)
+				common.ErrorWithHttpStatus(c, http.StatusInternalServerError, http.StatusInternalServerError, "auth lookup failed")
+			}
+			c.Abort()
+			return
+		}
+
+		if key.Revoked || (!key.ExpiresAt.IsZero() && time.Now().After(key.ExpiresAt)) {
+			slog.Warn("apikey_auth_failed_expired_or_revoked",
+				"request_id", requestID,
+				"api_key_id", key.ID,
+			)
+			common.ErrorWithHttpStatus(c, http.StatusUnauthorized, http.StatusUnauthorized, "API key expired or revoked")
+			c.Abort()
+			return
+		}
+
+		c.Set(APIKeyIDKey, key.ID)
+		c.Set(AllowedModelsKey, key.AllowedModels)
+		c.Set(RPMLimitKey, key.RPM)
+		c.Set(TPMLimitKey, key.TPM)
+		c.Set(DailyTokenLimitKey, key.DailyTokens)
+
+		slog.Debug("apikey_auth_success",
+			"request_id", requestID,
+			"api_key_id", key.ID,
+		)
+	}
+}
+
+// IsModelAllowed 判断当前API Key是否允许访问指定模型；未设置鉴权上下文或允许列表为空时不限制
+func IsModelAllowed(c *gin.Context, model string) bool {
+	allowed, exists := c.Get(AllowedModelsKey)
+	if !exists {
+		return true
+	}
+	allowedModels, ok := allowed.(models.StringList)
+	if !ok || len(allowedModels) == 0 {
+		return true
+	}
+	return slices.Contains(allowedModels, model)
+}
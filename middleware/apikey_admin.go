@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/atopos31/llmio/common"
+	"github.com/atopos31/llmio/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type createAPIKeyRequest struct {
+	Owner         string            `json:"owner" binding:"required"`
+	AllowedModels models.StringList `json:"allowed_models"`
+	RPM           int               `json:"rpm"`
+	TPM           int               `json:"tpm"`
+	DailyTokens   int64             `json:"daily_tokens"`
+}
+
+// CreateAPIKey 处理 POST /admin/apikeys，生成一个新的API Key并返回明文（仅此一次）
+func CreateAPIKey(c *gin.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ErrorWithHttpStatus(c, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		common.ErrorWithHttpStatus(c, http.StatusInternalServerError, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+
+	key := models.APIKey{
+		HashedKey:     hashToken(token),
+		Owner:         req.Owner,
+		AllowedModels: req.AllowedModels,
+		RPM:           req.RPM,
+		TPM:           req.TPM,
+		DailyTokens:   req.DailyTokens,
+	}
+	if err := gorm.G[models.APIKey](models.DB).Create(c.Request.Context(), &key); err != nil {
+		common.ErrorWithHttpStatus(c, http.StatusInternalServerError, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":    key.ID,
+		"token": token,
+	})
+}
+
+// ListAPIKeys 处理 GET /admin/apikeys
+func ListAPIKeys(c *gin.Context) {
+	keys, err := gorm.G[models.APIKey](models.DB).Find(c.Request.Context())
+	if err != nil {
+		common.ErrorWithHttpStatus(c, http.StatusInternalServerError, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+type updateAPIKeyRequest struct {
+	AllowedModels *models.StringList `json:"allowed_models"`
+	RPM           *int               `json:"rpm"`
+	TPM           *int               `json:"tpm"`
+	DailyTokens   *int64             `json:"daily_tokens"`
+	Revoked       *bool              `json:"revoked"`
+}
+
+// UpdateAPIKey 处理 PATCH /admin/apikeys/:id，更新配额或吊销状态
+func UpdateAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.ErrorWithHttpStatus(c, http.StatusBadRequest, http.StatusBadRequest, "invalid api key id")
+		return
+	}
+
+	var req updateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ErrorWithHttpStatus(c, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updates := make(map[string]any)
+	if req.AllowedModels != nil {
+		updates["allowed_models"] = *req.AllowedModels
+	}
+	if req.RPM != nil {
+		updates["rpm"] = *req.RPM
+	}
+	if req.TPM != nil {
+		updates["tpm"] = *req.TPM
+	}
+	if req.DailyTokens != nil {
+		updates["daily_tokens"] = *req.DailyTokens
+	}
+	if req.Revoked != nil {
+		updates["revoked"] = *req.Revoked
+	}
+
+	rows, err := gorm.G[models.APIKey](models.DB).Where("id = ?", uint(id)).Updates(c.Request.Context(), updates)
+	if err != nil {
+		common.ErrorWithHttpStatus(c, http.StatusInternalServerError, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if rows == 0 {
+		common.ErrorWithHttpStatus(c, http.StatusNotFound, http.StatusNotFound, "api key not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": rows})
+}
+
+// DeleteAPIKey 处理 DELETE /admin/apikeys/:id
+func DeleteAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.ErrorWithHttpStatus(c, http.StatusBadRequest, http.StatusBadRequest, "invalid api key id")
+		return
+	}
+
+	rows, err := gorm.G[models.APIKey](models.DB).Where("id = ?", uint(id)).Delete(c.Request.Context())
+	if err != nil {
+		common.ErrorWithHttpStatus(c, http.StatusInternalServerError, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if rows == 0 {
+		common.ErrorWithHttpStatus(c, http.StatusNotFound, http.StatusNotFound, "api key not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": rows})
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.New("failed to read random bytes")
+	}
+	return "sk-" + hex.EncodeToString(buf), nil
+}
@@ -53,6 +53,9 @@ func Auth(token string) gin.HandlerFunc {
 			return
 		}
 
+		// 静态token暂时视为单一身份，供计量/限流子系统按key归集用量
+		c.Set(APIKeyIDKey, uint(1))
+
 		slog.Debug("auth_success",
 			"request_id", requestID,
 		)
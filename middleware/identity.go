@@ -0,0 +1,17 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// APIKeyIDKey 是存储当前请求所属API Key标识的上下文键
+const APIKeyIDKey = "api_key_id"
+
+// GetAPIKeyID 从gin.Context中获取当前请求的API Key标识，
+// 未设置时返回0，表示匿名/未区分身份的请求
+func GetAPIKeyID(c *gin.Context) uint {
+	if id, exists := c.Get(APIKeyIDKey); exists {
+		if v, ok := id.(uint); ok {
+			return v
+		}
+	}
+	return 0
+}
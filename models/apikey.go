@@ -0,0 +1,48 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// StringList 以JSON数组形式持久化的字符串列表，用于APIKey.AllowedModels等字段
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	return json.Marshal(l)
+}
+
+func (l *StringList) Scan(value any) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+	raw, ok := value.([]byte)
+	if !ok {
+		s, ok := value.(string)
+		if !ok {
+			return errors.New("StringList: unsupported scan type")
+		}
+		raw = []byte(s)
+	}
+	return json.Unmarshal(raw, l)
+}
+
+// APIKey 是调用方凭据及其配额配置；原始token不落库，只保存其哈希值
+type APIKey struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	HashedKey     string     `json:"-" gorm:"uniqueIndex"`
+	Owner         string     `json:"owner"`
+	AllowedModels StringList `json:"allowed_models" gorm:"type:text"`
+	RPM           int        `json:"rpm"`
+	TPM           int        `json:"tpm"`
+	DailyTokens   int64      `json:"daily_tokens"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	Revoked       bool       `json:"revoked"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// ModelPrice 是按provider+model维护的单价表，单位为每1000 token的美元价格
+type ModelPrice struct {
+	ID                   uint    `json:"id" gorm:"primarykey"`
+	ProviderName         string  `json:"provider_name" gorm:"uniqueIndex:idx_provider_model_price"`
+	Model                string  `json:"model" gorm:"uniqueIndex:idx_provider_model_price"`
+	PromptPricePer1K     float64 `json:"prompt_price_per_1k"`
+	CompletionPricePer1K float64 `json:"completion_price_per_1k"`
+}
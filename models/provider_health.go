@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// ProviderHealth 是熔断器健康状态在短暂重启窗口间的快照，供balancer.Registry定期落盘/恢复
+type ProviderHealth struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	ProviderID  uint      `json:"provider_id" gorm:"uniqueIndex"`
+	State       string    `json:"state"`
+	HealthScore float64   `json:"health_score"`
+	ConsecFails int       `json:"consec_fails"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// UsageRecord 记录一次请求的token用量与费用，用于计量与计费查询
+type UsageRecord struct {
+	ID                  uint      `json:"id" gorm:"primarykey"`
+	ChatLogID           uint      `json:"chat_log_id" gorm:"index"`
+	APIKeyID            uint      `json:"api_key_id" gorm:"index"`
+	Model               string    `json:"model"`
+	ProviderName        string    `json:"provider_name"`
+	Style               string    `json:"style"`
+	TokensIn            int64     `json:"tokens_in"`
+	TokensOut           int64     `json:"tokens_out"`
+	CostUsd             float64   `json:"cost_usd"`
+	FirstTokenLatencyMs int64     `json:"first_token_latency_ms"`
+	TokensPerSecond     float64   `json:"tokens_per_second"`
+	CreatedAt           time.Time `json:"created_at"`
+}
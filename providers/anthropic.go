@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func init() {
+	Register("anthropic", newAnthropicModel)
+}
+
+// anthropicConfig 是Anthropic适配器的原始配置，JSON形式存储在provider.Config中
+type anthropicConfig struct {
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key"`
+}
+
+// anthropicModel 直接透传Anthropic Messages格式的请求，不做翻译
+type anthropicModel struct {
+	cfg anthropicConfig
+}
+
+func newAnthropicModel(config string) (ChatModel, error) {
+	var cfg anthropicConfig
+	if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid anthropic config: %w", err)
+	}
+	return &anthropicModel{cfg: cfg}, nil
+}
+
+func (a *anthropicModel) Capabilities() Capabilities {
+	return Capabilities{ToolCall: true, StructuredOutput: false, Image: true}
+}
+
+func (a *anthropicModel) Chat(ctx context.Context, client *http.Client, model string, raw []byte) (*http.Response, error) {
+	body, err := withModel(raw, model)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: rewrite model: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.BaseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", a.cfg.APIKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	return client.Do(req)
+}
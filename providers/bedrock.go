@@ -0,0 +1,119 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+func init() {
+	Register("bedrock", newBedrockModel)
+}
+
+// bedrockConfig 是AWS Bedrock适配器的原始配置，JSON形式存储在provider.Config中
+type bedrockConfig struct {
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"`
+}
+
+// bedrockModel 把OpenAI兼容的chat请求翻译为Bedrock的InvokeModel请求，
+// 用SigV4签名，并把响应重新编码为调用方期望的OpenAI兼容格式；
+// 流式请求(InvokeModelWithResponseStream)不受支持，Chat对stream=true的请求直接返回错误
+type bedrockModel struct {
+	cfg      bedrockConfig
+	signer   *v4.Signer
+	endpoint string
+}
+
+func newBedrockModel(config string) (ChatModel, error) {
+	var cfg bedrockConfig
+	if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid bedrock config: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("bedrock config missing region")
+	}
+	return &bedrockModel{
+		cfg:      cfg,
+		signer:   v4.NewSigner(),
+		endpoint: fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", cfg.Region),
+	}, nil
+}
+
+// Capabilities 只报告真正翻译支持的能力：chatRequest/chatMessage没有tools字段，
+// Content也只是纯文本，既不能表达工具调用也无法承载图片分片，因此这两项如实报false，
+// 避免BalanceChat把工具调用/视觉请求错误地路由到一个必然无法满足它们的adapter
+func (b *bedrockModel) Capabilities() Capabilities {
+	return Capabilities{ToolCall: false, StructuredOutput: false, Image: false}
+}
+
+// bedrockRequest 是Claude on Bedrock的请求体形状，沿用了Anthropic Messages API的结构
+type bedrockRequest struct {
+	AnthropicVersion string        `json:"anthropic_version"`
+	Messages         []chatMessage `json:"messages"`
+	MaxTokens        int           `json:"max_tokens"`
+}
+
+func (b *bedrockModel) Chat(ctx context.Context, client *http.Client, model string, raw []byte) (*http.Response, error) {
+	chatReq, err := parseChatRequest(raw)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: decode request: %w", err)
+	}
+	if chatReq.Stream {
+		// InvokeModelWithResponseStream返回的是application/vnd.amazon.eventstream二进制分帧，
+		// 不是纯文本SSE，目前还没有实现真正的分帧解码，宁可拒绝也不要把半成品的解析结果当成功返回
+		return nil, fmt.Errorf("bedrock: streaming is not yet supported")
+	}
+
+	body, err := json.Marshal(bedrockRequest{
+		AnthropicVersion: "bedrock-2023-05-31",
+		Messages:         chatReq.Messages,
+		MaxTokens:        4096,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/model/%s/invoke", b.endpoint, model)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	if err := b.sign(ctx, req, body); err != nil {
+		return nil, fmt.Errorf("bedrock: sign request: %w", err)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return reencodeBedrockResponse(res, chatReq.Model, chatReq.Stream)
+}
+
+func (b *bedrockModel) sign(ctx context.Context, req *http.Request, body []byte) error {
+	creds := aws.Credentials{
+		AccessKeyID:     b.cfg.AccessKeyID,
+		SecretAccessKey: b.cfg.SecretAccessKey,
+		SessionToken:    b.cfg.SessionToken,
+	}
+
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	return b.signer.SignHTTP(ctx, creds, req, payloadHash, "bedrock", b.cfg.Region, time.Now())
+}
@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+type bedrockContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type bedrockResponse struct {
+	Content []bedrockContentBlock `json:"content"`
+}
+
+type bedrockErrorBody struct {
+	Message string `json:"message"`
+}
+
+// reencodeBedrockResponse 把Bedrock/Claude的非流式响应重新编码为OpenAI兼容的chat.completion格式。
+// 流式场景(InvokeModelWithResponseStream)尚未支持，调用方在请求阶段已经拒绝，不会走到这里；
+// stream参数只是为了在支持流式解码之前提前暴露这个假设，一旦被意外传入true就直接报错
+func reencodeBedrockResponse(res *http.Response, model string, stream bool) (*http.Response, error) {
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: read response: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body = io.NopCloser(bytes.NewReader(normalizeBedrockError(raw)))
+		return res, nil
+	}
+
+	if stream {
+		return nil, fmt.Errorf("bedrock: streaming is not yet supported")
+	}
+
+	encoded, err := bedrockJSONToOpenAI(raw, model)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: translate response: %w", err)
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(encoded))
+	res.ContentLength = int64(len(encoded))
+	return res, nil
+}
+
+func normalizeBedrockError(raw []byte) []byte {
+	var bedrockErr bedrockErrorBody
+	if err := json.Unmarshal(raw, &bedrockErr); err != nil || bedrockErr.Message == "" {
+		return raw
+	}
+	out, err := json.Marshal(map[string]any{
+		"error": map[string]any{"message": bedrockErr.Message},
+	})
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
+func bedrockText(br bedrockResponse) string {
+	var text string
+	for _, block := range br.Content {
+		if block.Type == "text" {
+			text += block.Text
+		}
+	}
+	return text
+}
+
+func bedrockJSONToOpenAI(raw []byte, model string) ([]byte, error) {
+	var br bedrockResponse
+	if err := json.Unmarshal(raw, &br); err != nil {
+		return nil, err
+	}
+
+	resp := map[string]any{
+		"id":     fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+		"object": "chat.completion",
+		"model":  model,
+		"choices": []map[string]any{
+			{
+				"index": 0,
+				"message": map[string]any{
+					"role":    "assistant",
+					"content": bedrockText(br),
+				},
+				"finish_reason": "stop",
+			},
+		},
+	}
+	return json.Marshal(resp)
+}
+
@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func init() {
+	Register("gemini", newGeminiModel)
+}
+
+// geminiConfig 是Gemini适配器的原始配置，JSON形式存储在provider.Config中
+type geminiConfig struct {
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key"`
+}
+
+// geminiModel 把OpenAI兼容的chat请求翻译为Gemini的generateContent/streamGenerateContent，
+// 并把Gemini的响应重新编码为调用方期望的OpenAI兼容格式
+type geminiModel struct {
+	cfg geminiConfig
+}
+
+func newGeminiModel(config string) (ChatModel, error) {
+	var cfg geminiConfig
+	if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid gemini config: %w", err)
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	}
+	return &geminiModel{cfg: cfg}, nil
+}
+
+// Capabilities 只报告真正翻译支持的能力：chatRequest/chatMessage没有tools字段，
+// Content也只是纯文本，既不能表达工具调用也无法承载图片分片，因此这两项如实报false；
+// Chat也完全没有转发或处理结构化输出相关的字段，同样如实报false，
+// 避免BalanceChat把工具调用/结构化输出/视觉请求错误地路由到一个必然无法满足它们的adapter
+func (g *geminiModel) Capabilities() Capabilities {
+	return Capabilities{ToolCall: false, StructuredOutput: false, Image: false}
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	Contents []geminiContent `json:"contents"`
+}
+
+func toGeminiRole(role string) string {
+	if role == "assistant" {
+		return "model"
+	}
+	return "user"
+}
+
+func (g *geminiModel) Chat(ctx context.Context, client *http.Client, model string, raw []byte) (*http.Response, error) {
+	chatReq, err := parseChatRequest(raw)
+	if err != nil {
+		return nil, fmt.Errorf("gemini: decode request: %w", err)
+	}
+
+	geminiReq := geminiRequest{Contents: make([]geminiContent, 0, len(chatReq.Messages))}
+	for _, m := range chatReq.Messages {
+		geminiReq.Contents = append(geminiReq.Contents, geminiContent{
+			Role:  toGeminiRole(m.Role),
+			Parts: []geminiPart{{Text: m.Content}},
+		})
+	}
+	body, err := json.Marshal(geminiReq)
+	if err != nil {
+		return nil, err
+	}
+
+	action := "generateContent"
+	if chatReq.Stream {
+		action = "streamGenerateContent?alt=sse"
+	}
+	url := fmt.Sprintf("%s/models/%s:%s", g.cfg.BaseURL, model, action)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", g.cfg.APIKey)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return reencodeGeminiResponse(res, chatReq.Model, chatReq.Stream)
+}
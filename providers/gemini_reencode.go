@@ -0,0 +1,147 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+type geminiCandidate struct {
+	Content struct {
+		Parts []geminiPart `json:"parts"`
+	} `json:"content"`
+	FinishReason string `json:"finishReason"`
+}
+
+type geminiResponse struct {
+	Candidates []geminiCandidate `json:"candidates"`
+}
+
+type geminiErrorBody struct {
+	Error struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// reencodeGeminiResponse 把Gemini的响应体重新编码为OpenAI兼容的chat.completion(chunk)格式，
+// 非2xx状态码时把错误体规整为OpenAI风格的{"error":{...}}，但保留原始HTTP状态码，
+// 使BalanceChat的重试逻辑(429 vs 5xx vs网络错误)无需区分具体provider即可统一工作
+func reencodeGeminiResponse(res *http.Response, model string, stream bool) (*http.Response, error) {
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("gemini: read response: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body = io.NopCloser(bytes.NewReader(normalizeGeminiError(raw)))
+		return res, nil
+	}
+
+	var encoded []byte
+	if stream {
+		encoded = geminiSSEToOpenAI(raw, model)
+	} else {
+		encoded, err = geminiJSONToOpenAI(raw, model)
+		if err != nil {
+			return nil, fmt.Errorf("gemini: translate response: %w", err)
+		}
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(encoded))
+	res.ContentLength = int64(len(encoded))
+	return res, nil
+}
+
+func normalizeGeminiError(raw []byte) []byte {
+	var geminiErr geminiErrorBody
+	if err := json.Unmarshal(raw, &geminiErr); err != nil || geminiErr.Error.Message == "" {
+		return raw
+	}
+	out, err := json.Marshal(map[string]any{
+		"error": map[string]any{
+			"message": geminiErr.Error.Message,
+			"type":    geminiErr.Error.Status,
+		},
+	})
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
+func geminiCandidateText(gr geminiResponse) string {
+	if len(gr.Candidates) == 0 || len(gr.Candidates[0].Content.Parts) == 0 {
+		return ""
+	}
+	return gr.Candidates[0].Content.Parts[0].Text
+}
+
+func geminiJSONToOpenAI(raw []byte, model string) ([]byte, error) {
+	var gr geminiResponse
+	if err := json.Unmarshal(raw, &gr); err != nil {
+		return nil, err
+	}
+
+	resp := map[string]any{
+		"id":     fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+		"object": "chat.completion",
+		"model":  model,
+		"choices": []map[string]any{
+			{
+				"index": 0,
+				"message": map[string]any{
+					"role":    "assistant",
+					"content": geminiCandidateText(gr),
+				},
+				"finish_reason": "stop",
+			},
+		},
+	}
+	return json.Marshal(resp)
+}
+
+func geminiSSEToOpenAI(raw []byte, model string) []byte {
+	var out bytes.Buffer
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		data, found := bytes.CutPrefix(line, []byte("data:"))
+		if !found {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		var gr geminiResponse
+		if err := json.Unmarshal(data, &gr); err != nil {
+			continue
+		}
+
+		chunk := map[string]any{
+			"id":     fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+			"object": "chat.completion.chunk",
+			"model":  model,
+			"choices": []map[string]any{
+				{
+					"index": 0,
+					"delta": map[string]any{"content": geminiCandidateText(gr)},
+				},
+			},
+		}
+		encoded, err := json.Marshal(chunk)
+		if err != nil {
+			continue
+		}
+		out.WriteString("data: ")
+		out.Write(encoded)
+		out.WriteString("\n\n")
+	}
+	out.WriteString("data: [DONE]\n\n")
+	return out.Bytes()
+}
@@ -0,0 +1,52 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func init() {
+	Register("openai", newOpenAIModel)
+}
+
+// openaiConfig 是OpenAI兼容适配器的原始配置，JSON形式存储在provider.Config中
+type openaiConfig struct {
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key"`
+}
+
+// openaiModel 直接透传OpenAI兼容格式的请求，不做翻译
+type openaiModel struct {
+	cfg openaiConfig
+}
+
+func newOpenAIModel(config string) (ChatModel, error) {
+	var cfg openaiConfig
+	if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid openai config: %w", err)
+	}
+	return &openaiModel{cfg: cfg}, nil
+}
+
+func (o *openaiModel) Capabilities() Capabilities {
+	return Capabilities{ToolCall: true, StructuredOutput: true, Image: true}
+}
+
+func (o *openaiModel) Chat(ctx context.Context, client *http.Client, model string, raw []byte) (*http.Response, error) {
+	body, err := withModel(raw, model)
+	if err != nil {
+		return nil, fmt.Errorf("openai: rewrite model: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+o.cfg.APIKey)
+
+	return client.Do(req)
+}
@@ -0,0 +1,32 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ChatModel 是所有provider适配器必须实现的统一接口；
+// BalanceChat依赖它发起请求并取回调用方style所约定格式的HTTP响应
+type ChatModel interface {
+	// Chat 向上游发起一次请求，raw是调用方原始的请求体
+	Chat(ctx context.Context, client *http.Client, model string, raw []byte) (*http.Response, error)
+}
+
+// Capabilities 描述一个provider对工具调用/结构化输出/视觉输入的原生支持情况
+type Capabilities struct {
+	ToolCall         bool `json:"tool_call"`
+	StructuredOutput bool `json:"structured_output"`
+	Image            bool `json:"image"`
+}
+
+// CapabilityReporter 由能够自报能力的适配器实现；
+// 未实现该接口的适配器视为需要手动配置模型-provider关联的能力开关
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// GetClient 返回一个带有指定超时时间的HTTP客户端
+func GetClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout}
+}
@@ -0,0 +1,37 @@
+package providers
+
+import "fmt"
+
+// Factory 根据provider的原始配置(JSON字符串)构造一个ChatModel适配器实例
+type Factory func(config string) (ChatModel, error)
+
+var registry = make(map[string]Factory)
+
+// Register 把一个style对应的适配器工厂注册到全局表；
+// 每个适配器应在自己的init()中调用Register，New()不再需要为每个style写死switch分支
+func Register(style string, factory Factory) {
+	registry[style] = factory
+}
+
+// New 按style从注册表中查找对应的适配器工厂并构造ChatModel实例
+func New(style string, config string) (ChatModel, error) {
+	factory, ok := registry[style]
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider style: %s", style)
+	}
+	return factory(config)
+}
+
+// Capabilities 查询某个style下给定配置实例化后的适配器能力；
+// 未实现CapabilityReporter的适配器返回zero value，调用方应回退到手动配置的能力开关
+func Capabilities(style string, config string) (Capabilities, error) {
+	model, err := New(style, config)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	reporter, ok := model.(CapabilityReporter)
+	if !ok {
+		return Capabilities{}, nil
+	}
+	return reporter.Capabilities(), nil
+}
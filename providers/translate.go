@@ -0,0 +1,39 @@
+package providers
+
+import "encoding/json"
+
+// chatRequest 是BalanceChat传入的、OpenAI兼容请求体的最小可解析子集，
+// 供需要做请求翻译的适配器（Gemini、Bedrock）复用
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Stream   bool          `json:"stream"`
+	Messages []chatMessage `json:"messages"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// parseChatRequest 解析OpenAI兼容的请求体
+func parseChatRequest(raw []byte) (chatRequest, error) {
+	var req chatRequest
+	err := json.Unmarshal(raw, &req)
+	return req, err
+}
+
+// withModel 把原始请求体中的"model"字段替换为调用方解析出的provider实际模型名，
+// 其余字段原样保留。用于直接透传请求体的适配器（OpenAI、Anthropic），
+// 避免ProviderModel与客户端可见的模型名不一致时把错误的模型名发给上游
+func withModel(raw []byte, model string) ([]byte, error) {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, err
+	}
+	encodedModel, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	body["model"] = encodedModel
+	return json.Marshal(body)
+}
@@ -0,0 +1,120 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Usage 是一次请求的prompt/completion token用量
+type Usage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+// ParseUsage 尝试从一个SSE/JSON响应分片中增量提取token用量。
+// 不同style的上游在不同位置、不同时机给出usage字段，因此采用尽力而为的方式：
+// 识别到用量信息的分片返回ok=true，否则返回false，调用方应继续喂入下一个分片。
+func ParseUsage(style string, chunk []byte) (usage Usage, ok bool) {
+	switch style {
+	case "openai":
+		return parseOpenAIUsage(chunk)
+	case "anthropic":
+		return parseAnthropicUsage(chunk)
+	default:
+		return Usage{}, false
+	}
+}
+
+// openAIUsageFrame是OpenAI chat completion一个usage字段的通用形状，
+// 流式SSE分片和非流式整份JSON响应体共用同一个结构
+type openAIUsageFrame struct {
+	Usage *struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+func parseOpenAIUsage(chunk []byte) (Usage, bool) {
+	trimmed := bytes.TrimSpace(chunk)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		// 非流式响应是一份完整的JSON对象，没有"data:"前缀，按普通JSON整体解析
+		var frame openAIUsageFrame
+		if err := json.Unmarshal(trimmed, &frame); err == nil && frame.Usage != nil {
+			return Usage{PromptTokens: frame.Usage.PromptTokens, CompletionTokens: frame.Usage.CompletionTokens}, true
+		}
+	}
+
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		data, found := bytes.CutPrefix(line, []byte("data:"))
+		if !found {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 || string(data) == "[DONE]" {
+			continue
+		}
+		var frame openAIUsageFrame
+		if err := json.Unmarshal(data, &frame); err != nil || frame.Usage == nil {
+			continue
+		}
+		return Usage{PromptTokens: frame.Usage.PromptTokens, CompletionTokens: frame.Usage.CompletionTokens}, true
+	}
+	return Usage{}, false
+}
+
+// anthropicUsageFrame是Anthropic messages响应一个usage字段的通用形状，流式SSE分片
+// (message_start/message_delta事件)和非流式整份JSON响应体(顶层message)共用同一个结构
+type anthropicUsageFrame struct {
+	Type  string `json:"type"`
+	Usage *struct {
+		InputTokens  int64 `json:"input_tokens"`
+		OutputTokens int64 `json:"output_tokens"`
+	} `json:"usage"`
+	Message *struct {
+		Usage *struct {
+			InputTokens  int64 `json:"input_tokens"`
+			OutputTokens int64 `json:"output_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+}
+
+func parseAnthropicUsage(chunk []byte) (Usage, bool) {
+	trimmed := bytes.TrimSpace(chunk)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		// 非流式响应是一份完整的JSON对象，usage字段直接挂在顶层而不是message里，
+		// 没有"data:"前缀，按普通JSON整体解析
+		var frame struct {
+			Usage *struct {
+				InputTokens  int64 `json:"input_tokens"`
+				OutputTokens int64 `json:"output_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal(trimmed, &frame); err == nil && frame.Usage != nil {
+			return Usage{PromptTokens: frame.Usage.InputTokens, CompletionTokens: frame.Usage.OutputTokens}, true
+		}
+	}
+
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		data, found := bytes.CutPrefix(line, []byte("data:"))
+		if !found {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+		var frame anthropicUsageFrame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			continue
+		}
+		switch {
+		case frame.Message != nil && frame.Message.Usage != nil:
+			return Usage{PromptTokens: frame.Message.Usage.InputTokens, CompletionTokens: frame.Message.Usage.OutputTokens}, true
+		case frame.Type == "message_delta" && frame.Usage != nil:
+			return Usage{CompletionTokens: frame.Usage.OutputTokens}, true
+		}
+	}
+	return Usage{}, false
+}
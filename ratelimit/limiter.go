@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter 按key在固定窗口内限制请求/用量次数；
+// 同时支持预先扣减配额(Allow)与事后核销配额(Commit)两种用法，
+// 前者用于RPM等必须前置拒绝的场景，后者用于TPM/每日token等需要响应结束后才知道真实用量的场景
+type Limiter interface {
+	// Allow 尝试在window窗口内为key消费cost个配额单位；超过limit时拒绝且不计入已用量
+	Allow(ctx context.Context, key string, limit int, window time.Duration, cost int) (allowed bool, retryAfter time.Duration, err error)
+	// Commit 无条件为key在window窗口内累加cost个配额单位，用于事后核销
+	Commit(ctx context.Context, key string, window time.Duration, cost int) error
+}
+
+type counter struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	used        int
+}
+
+// MemoryLimiter 是进程内的固定窗口限流器，适用于单实例部署
+type MemoryLimiter struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+}
+
+// NewMemoryLimiter 创建一个空的内存限流器
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{counters: make(map[string]*counter)}
+}
+
+func (m *MemoryLimiter) get(key string) *counter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.counters[key]
+	if !ok {
+		c = &counter{windowStart: time.Now()}
+		m.counters[key] = c
+	}
+	return c
+}
+
+func (m *MemoryLimiter) Allow(_ context.Context, key string, limit int, window time.Duration, cost int) (bool, time.Duration, error) {
+	if limit <= 0 {
+		return true, 0, nil
+	}
+	c := m.get(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.windowStart) >= window {
+		c.windowStart = now
+		c.used = 0
+	}
+
+	if c.used+cost > limit {
+		return false, window - now.Sub(c.windowStart), nil
+	}
+
+	c.used += cost
+	return true, 0, nil
+}
+
+func (m *MemoryLimiter) Commit(_ context.Context, key string, window time.Duration, cost int) error {
+	c := m.get(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.windowStart) >= window {
+		c.windowStart = now
+		c.used = 0
+	}
+	c.used += cost
+	return nil
+}
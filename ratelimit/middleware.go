@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atopos31/llmio/common"
+	"github.com/atopos31/llmio/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// Default 是进程级的默认限流器；多实例部署时应在启动时替换为NewRedisLimiter
+var Default Limiter = NewMemoryLimiter()
+
+// RateLimit 在Auth之后运行，对请求按API Key+模型做RPM限流。
+// TPM与每日token配额无法在请求进入时得知（要等上游返回usage），
+// 因此由metering子系统在响应结束后事后核销，见CommitTokenUsage；
+// 这里只检查已核销的用量是否已经超过配额，超过则拒绝后续请求。
+func RateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKeyID := middleware.GetAPIKeyID(c)
+
+		raw, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			common.ErrorWithHttpStatus(c, http.StatusBadRequest, http.StatusBadRequest, "failed to read request body")
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.Unmarshal(raw, &body)
+
+		if !middleware.IsModelAllowed(c, body.Model) {
+			common.ErrorWithHttpStatus(c, http.StatusForbidden, http.StatusForbidden, "model not allowed for this API key")
+			c.Abort()
+			return
+		}
+
+		// 以cost=0检查CommitTokenUsage累加的TPM/每日token用量是否已超额
+		tpm, _ := c.Get(middleware.TPMLimitKey)
+		daily, _ := c.Get(middleware.DailyTokenLimitKey)
+		tpmQuota, _ := tpm.(int)
+		dailyQuota, _ := daily.(int64)
+		tpmOK, _, tpmErr := Default.Allow(c.Request.Context(), fmt.Sprintf("tpm:%d", apiKeyID), tpmQuota, time.Minute, 0)
+		dailyOK, _, dailyErr := Default.Allow(c.Request.Context(), fmt.Sprintf("daily:%d", apiKeyID), int(dailyQuota), 24*time.Hour, 0)
+		if (tpmErr == nil && !tpmOK) || (dailyErr == nil && !dailyOK) {
+			common.ErrorWithHttpStatus(c, http.StatusTooManyRequests, http.StatusTooManyRequests, "token quota exceeded")
+			c.Abort()
+			return
+		}
+
+		limit := rpmLimit(c)
+		key := fmt.Sprintf("rpm:%d:%s", apiKeyID, body.Model)
+		allowed, retryAfter, err := Default.Allow(c.Request.Context(), key, limit, time.Minute, 1)
+		if err != nil {
+			common.ErrorWithHttpStatus(c, http.StatusInternalServerError, http.StatusInternalServerError, "rate limit check failed")
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+1)))
+			c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+			c.Header("X-RateLimit-Remaining", "0")
+			common.ErrorWithHttpStatus(c, http.StatusTooManyRequests, http.StatusTooManyRequests, "rate limit exceeded")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CommitTokenUsage 事后核销一次请求消耗的token，计入TPM与每日token配额；
+// 即使本次已经超额也无条件累加，以便正确限制后续请求
+func CommitTokenUsage(ctx context.Context, apiKeyID uint, tpmLimit int, dailyLimit int64, tokens int64) {
+	if tpmLimit > 0 {
+		_ = Default.Commit(ctx, fmt.Sprintf("tpm:%d", apiKeyID), time.Minute, int(tokens))
+	}
+	if dailyLimit > 0 {
+		_ = Default.Commit(ctx, fmt.Sprintf("daily:%d", apiKeyID), 24*time.Hour, int(tokens))
+	}
+}
+
+func rpmLimit(c *gin.Context) int {
+	if v, exists := c.Get(middleware.RPMLimitKey); exists {
+		if limit, ok := v.(int); ok {
+			return limit
+		}
+	}
+	return 0
+}
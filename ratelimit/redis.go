@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisLimiter 是基于Redis INCRBY+EXPIRE实现的固定窗口限流器，
+// 用于多实例部署下跨进程共享RPM/TPM/每日token配额状态
+type RedisLimiter struct {
+	client *redis.Client
+}
+
+// NewRedisLimiter 用给定的Redis客户端创建限流器
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{client: client}
+}
+
+func (r *RedisLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration, cost int) (bool, time.Duration, error) {
+	if limit <= 0 {
+		return true, 0, nil
+	}
+
+	used, err := r.client.IncrBy(ctx, key, int64(cost)).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	if used > int64(limit) {
+		// 超额的这次不计入配额，回退刚才的增量
+		if _, err := r.client.DecrBy(ctx, key, int64(cost)).Result(); err != nil {
+			return false, 0, err
+		}
+		ttl, err := r.client.TTL(ctx, key).Result()
+		if err != nil {
+			return false, 0, err
+		}
+		return false, ttl, nil
+	}
+
+	if used == int64(cost) {
+		// 本次是该窗口的第一次写入，设置过期时间开启新窗口
+		if err := r.client.Expire(ctx, key, window).Err(); err != nil {
+			return false, 0, err
+		}
+	}
+
+	return true, 0, nil
+}
+
+func (r *RedisLimiter) Commit(ctx context.Context, key string, window time.Duration, cost int) error {
+	used, err := r.client.IncrBy(ctx, key, int64(cost)).Result()
+	if err != nil {
+		return err
+	}
+	if used == int64(cost) {
+		return r.client.Expire(ctx, key, window).Err()
+	}
+	return nil
+}
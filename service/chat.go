@@ -8,9 +8,12 @@ import (
 	"log/slog"
 	"net/http"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/atopos31/llmio/balancer"
+	"github.com/atopos31/llmio/cache"
+	"github.com/atopos31/llmio/metering"
 	"github.com/atopos31/llmio/middleware"
 	"github.com/atopos31/llmio/models"
 	"github.com/atopos31/llmio/providers"
@@ -55,6 +58,47 @@ func BalanceChat(c *gin.Context, style string, Beforer Beforer, processer Proces
 		"image", before.image,
 	)
 
+	apiKeyID := middleware.GetAPIKeyID(c)
+	cacheEntry, cacheStatus := cache.Lookup(ctx, rawData, strconv.FormatUint(uint64(apiKeyID), 10))
+	c.Header("X-Cache", cacheStatus)
+	if cacheStatus == cache.HitExact || cacheStatus == cache.HitSemantic {
+		slog.Info("cache_hit",
+			"request_id", requestID,
+			"model", before.model,
+			"status", cacheStatus,
+		)
+
+		log := models.ChatLog{
+			Name:          before.model,
+			Status:        "success",
+			Style:         style,
+			ProviderName:  "cache",
+			ProviderModel: before.model,
+			ProxyTime:     time.Since(proxyStart),
+		}
+		logId, err := SaveChatLog(ctx, log)
+		if err != nil {
+			slog.Error("save chat log error", "error", err)
+		}
+
+		// 缓存命中没有真正调用上游，但token计数是缓存写入时就算好的真实值，
+		// 仍要记进UsageRecord并核销TPM/每日token配额，否则一个key可以无限消费
+		// 缓存内容而不触及配额限制，也在/admin/usage里完全看不到这部分用量
+		metering.RecordCacheHit(ctx, metering.RequestInfo{
+			Style:           style,
+			Model:           before.model,
+			ProviderName:    "cache",
+			ProviderModel:   before.model,
+			ChatLogID:       logId,
+			APIKeyID:        apiKeyID,
+			TPMLimit:        tpmLimit(c),
+			DailyTokenLimit: dailyTokenLimit(c),
+		}, cacheEntry.TokensIn, cacheEntry.TokensOut)
+
+		cache.Replay(c, cacheEntry)
+		return nil
+	}
+
 	llmProvidersWithLimit, err := ProvidersBymodelsName(ctx, before.model)
 	if err != nil {
 		slog.Error("failed_to_get_providers",
@@ -102,24 +146,41 @@ func BalanceChat(c *gin.Context, style string, Beforer Beforer, processer Proces
 
 	items := make(map[uint]int)
 	for _, modelWithProvider := range llmproviders {
+		provider := providerMap[modelWithProvider.ProviderID]
+		// 过滤提供商类型
+		if provider == nil || provider.Type != style {
+			continue
+		}
+
+		// 未手动配置能力开关时，回退到适配器自报的能力，而不是默认当作支持
+		caps, err := providers.Capabilities(style, provider.Config)
+		if err != nil {
+			slog.Warn("capabilities_probe_failed",
+				"request_id", requestID,
+				"provider", provider.Name,
+				"error", err,
+			)
+		}
+
 		// 过滤是否开启工具调用
-		if modelWithProvider.ToolCall != nil && before.toolCall && !*modelWithProvider.ToolCall {
+		if !allowsCapability(modelWithProvider.ToolCall, caps.ToolCall, before.toolCall) {
 			continue
 		}
 		// 过滤是否开启结构化输出
-		if modelWithProvider.StructuredOutput != nil && before.structuredOutput && !*modelWithProvider.StructuredOutput {
+		if !allowsCapability(modelWithProvider.StructuredOutput, caps.StructuredOutput, before.structuredOutput) {
 			continue
 		}
 		// 过滤是否拥有视觉能力
-		if modelWithProvider.Image != nil && before.image && !*modelWithProvider.Image {
+		if !allowsCapability(modelWithProvider.Image, caps.Image, before.image) {
 			continue
 		}
-		provider := providerMap[modelWithProvider.ProviderID]
-		// 过滤提供商类型
-		if provider == nil || provider.Type != style {
+
+		// 熔断器处于Open状态的provider完全跳过，不参与本轮负载均衡
+		if !balancer.Default.Allow(provider.ID) {
 			continue
 		}
-		items[modelWithProvider.ID] = modelWithProvider.Weight
+		// 按健康分折算配置权重，健康分越低被选中概率越低
+		items[modelWithProvider.ID] = balancer.Default.EffectiveWeight(provider.ID, modelWithProvider.Weight)
 	}
 
 	if len(items) == 0 {
@@ -170,97 +231,155 @@ func BalanceChat(c *gin.Context, style string, Beforer Beforer, processer Proces
 			)
 			return errors.New("retry time out !")
 		default:
-			// 加权负载均衡
-			item, err := balancer.WeightedRandom(items)
-			if err != nil {
-				return err
+			log := models.ChatLog{
+				Name:      before.model,
+				Status:    "success",
+				Style:     style,
+				Retry:     retry,
+				ProxyTime: time.Since(proxyStart),
 			}
-			modelWithProviderIndex := slices.IndexFunc(llmproviders, func(mp models.ModelWithProvider) bool {
-				return mp.ID == *item
-			})
-			modelWithProvider := llmproviders[modelWithProviderIndex]
+			client := providers.GetClient(time.Second * time.Duration(llmProvidersWithLimit.TimeOut) / 3)
 
-			provider := providerMap[modelWithProvider.ProviderID]
+			hedgeAfter := time.Duration(llmProvidersWithLimit.HedgeAfterMs) * time.Millisecond
+			// 工具调用存在副作用风险，一旦出现重复的并发调用可能导致外部状态被执行两次，
+			// 因此工具调用场景下强制关闭对冲，退化为原有的顺序重试
+			hedgingEnabled := hedgeAfter > 0 && llmProvidersWithLimit.MaxHedges > 0 && !before.toolCall
 
-			chatModel, err := providers.New(style, provider.Config)
-			if err != nil {
-				slog.Error("failed_to_create_provider_client",
+			var outcome attemptOutcome
+			if hedgingEnabled {
+				slog.Info("hedging_enabled",
 					"request_id", requestID,
-					"provider", provider.Name,
-					"error", err,
+					"hedge_after_ms", llmProvidersWithLimit.HedgeAfterMs,
+					"max_hedges", llmProvidersWithLimit.MaxHedges,
+					"retry", retry,
 				)
-				return err
-			}
+				o, err := selectHedgedWinner(ctx, requestID, style, client, items, llmproviders, providerMap, before.raw, hedgeAfter, llmProvidersWithLimit.MaxHedges, retryErrLog, log)
+				if err != nil {
+					slog.Error("hedged_attempts_exhausted",
+						"request_id", requestID,
+						"retry", retry,
+						"error", err,
+					)
+					continue
+				}
+				outcome = o
+			} else {
+				// 加权负载均衡
+				item, err := balancer.WeightedRandom(items)
+				if err != nil {
+					return err
+				}
+				modelWithProviderIndex := slices.IndexFunc(llmproviders, func(mp models.ModelWithProvider) bool {
+					return mp.ID == *item
+				})
+				modelWithProvider := llmproviders[modelWithProviderIndex]
 
-			slog.Info("provider_selected",
-				"request_id", requestID,
-				"provider", provider.Name,
-				"provider_model", modelWithProvider.ProviderModel,
-				"retry", retry,
-			)
+				provider := providerMap[modelWithProvider.ProviderID]
 
-			log := models.ChatLog{
-				Name:          before.model,
-				ProviderModel: modelWithProvider.ProviderModel,
-				ProviderName:  provider.Name,
-				Status:        "success",
-				Style:         style,
-				Retry:         retry,
-				ProxyTime:     time.Since(proxyStart),
-			}
-			reqStart := time.Now()
-			client := providers.GetClient(time.Second * time.Duration(llmProvidersWithLimit.TimeOut) / 3)
+				// HalfOpen探测名额只在真正下发请求的这一刻获取，避免被候选列表中
+				// 未被选中的provider白白占用
+				if !balancer.Default.Acquire(provider.ID) {
+					slog.Warn("circuit_breaker_permit_denied",
+						"request_id", requestID,
+						"provider", provider.Name,
+						"retry", retry,
+					)
+					delete(items, *item)
+					continue
+				}
 
-			slog.Info("sending_request_to_provider",
-				"request_id", requestID,
-				"provider", provider.Name,
-				"timeout_seconds", llmProvidersWithLimit.TimeOut/3,
-			)
+				chatModel, err := providers.New(style, provider.Config)
+				if err != nil {
+					slog.Error("failed_to_create_provider_client",
+						"request_id", requestID,
+						"provider", provider.Name,
+						"error", err,
+					)
+					return err
+				}
 
-			res, err := chatModel.Chat(ctx, client, modelWithProvider.ProviderModel, before.raw)
-			if err != nil {
-				slog.Error("provider_request_failed",
+				slog.Info("provider_selected",
 					"request_id", requestID,
 					"provider", provider.Name,
+					"provider_model", modelWithProvider.ProviderModel,
 					"retry", retry,
-					"error", err,
 				)
-				retryErrLog <- log.WithError(err)
-				// 请求失败 移除待选
-				delete(items, *item)
-				continue
-			}
 
-			if res.StatusCode != http.StatusOK {
-				byteBody, err := io.ReadAll(res.Body)
-				if err != nil {
-					slog.Error("read body error", "error", err)
-				}
-				slog.Error("provider_returned_error_status",
+				reqStart := time.Now()
+
+				slog.Info("sending_request_to_provider",
 					"request_id", requestID,
 					"provider", provider.Name,
-					"status_code", res.StatusCode,
-					"response_body", string(byteBody),
-					"retry", retry,
+					"timeout_seconds", llmProvidersWithLimit.TimeOut/3,
 				)
-				retryErrLog <- log.WithError(fmt.Errorf("status: %d, body: %s", res.StatusCode, string(byteBody)))
 
-				if res.StatusCode == http.StatusTooManyRequests {
-					slog.Warn("rate_limit_hit",
+				// 失败日志要归属到本次实际尝试的provider，而不是等胜出后才回填provider字段的log，
+				// 否则重试过程中写入的失败行在ChatLog/admin面板里查不到是哪个provider出的问题
+				providerLog := log
+				providerLog.ProviderName = provider.Name
+				providerLog.ProviderModel = modelWithProvider.ProviderModel
+
+				res, err := chatModel.Chat(ctx, client, modelWithProvider.ProviderModel, before.raw)
+				if err != nil {
+					slog.Error("provider_request_failed",
 						"request_id", requestID,
 						"provider", provider.Name,
+						"retry", retry,
+						"error", err,
 					)
-					// 达到RPM限制 降低权重
-					items[*item] -= items[*item] / 3
-				} else {
-					// 非RPM限制 移除待选
+					retryErrLog <- providerLog.WithError(err)
+					// 请求失败 记录熔断器失败并移除待选
+					balancer.Default.RecordFailure(provider.ID)
 					delete(items, *item)
+					continue
 				}
-				res.Body.Close()
-				continue
+
+				if res.StatusCode != http.StatusOK {
+					byteBody, err := io.ReadAll(res.Body)
+					if err != nil {
+						slog.Error("read body error", "error", err)
+					}
+					slog.Error("provider_returned_error_status",
+						"request_id", requestID,
+						"provider", provider.Name,
+						"status_code", res.StatusCode,
+						"response_body", string(byteBody),
+						"retry", retry,
+					)
+					retryErrLog <- providerLog.WithError(fmt.Errorf("status: %d, body: %s", res.StatusCode, string(byteBody)))
+
+					if res.StatusCode == http.StatusTooManyRequests {
+						slog.Warn("rate_limit_hit",
+							"request_id", requestID,
+							"provider", provider.Name,
+						)
+						// 达到RPM限制 降低权重，并归还可能占用的HalfOpen探测名额(限流不计入熔断失败)
+						items[*item] -= items[*item] / 3
+						balancer.Default.ReleaseProbe(provider.ID)
+					} else {
+						// 非RPM限制 记录熔断器失败并移除待选
+						balancer.Default.RecordFailure(provider.ID)
+						delete(items, *item)
+					}
+					res.Body.Close()
+					continue
+				}
+
+				outcome = attemptOutcome{modelWithProvider: modelWithProvider, provider: provider, res: res, reqStart: reqStart}
 			}
+
+			modelWithProvider := outcome.modelWithProvider
+			provider := outcome.provider
+			res := outcome.res
+			reqStart := outcome.reqStart
+
+			log.ProviderModel = modelWithProvider.ProviderModel
+			log.ProviderName = provider.Name
+
 			defer res.Body.Close()
 
+			balancer.Default.RecordSuccess(provider.ID, time.Since(reqStart))
+
 			slog.Info("provider_response_success",
 				"request_id", requestID,
 				"provider", provider.Name,
@@ -275,11 +394,43 @@ func BalanceChat(c *gin.Context, style string, Beforer Beforer, processer Proces
 			pr, pw := io.Pipe()
 			tee := io.TeeReader(res.Body, pw)
 
+			mr, mw := io.Pipe()
+			cr, cw := io.Pipe()
+
 			// 与客户端并行处理响应数据流 同时记录日志
 			go func(ctx context.Context) {
 				defer pr.Close()
-				processer(ctx, pr, before.stream, logId, reqStart)
+				defer mw.Close()
+				defer cw.Close()
+				processer(ctx, io.TeeReader(pr, io.MultiWriter(mw, cw)), before.stream, logId, reqStart)
 			}(context.Background())
+
+			// 缓存本次上游响应，供后续相同/相似请求命中
+			go func() {
+				defer cr.Close()
+				body, err := io.ReadAll(cr)
+				if err != nil {
+					slog.Warn("cache_store_read_error", "request_id", requestID, "error", err)
+					return
+				}
+				cache.Store(context.Background(), style, rawData, body, before.stream)
+			}()
+
+			// 增量解析token用量并计费，与processer共享同一份响应数据
+			go func() {
+				defer mr.Close()
+				metering.Meter(context.Background(), metering.RequestInfo{
+					Style:           style,
+					Model:           before.model,
+					ProviderName:    provider.Name,
+					ProviderModel:   modelWithProvider.ProviderModel,
+					ChatLogID:       logId,
+					APIKeyID:        middleware.GetAPIKeyID(c),
+					TPMLimit:        tpmLimit(c),
+					DailyTokenLimit: dailyTokenLimit(c),
+					Start:           reqStart,
+				}, mr)
+			}()
 			// 转发给客户端
 			if before.stream {
 				c.Header("Content-Type", "text/event-stream")
@@ -310,9 +461,11 @@ func SaveChatLog(ctx context.Context, log models.ChatLog) (uint, error) {
 }
 
 type ProvidersWithlimit struct {
-	Providers []models.ModelWithProvider
-	MaxRetry  int
-	TimeOut   int
+	Providers    []models.ModelWithProvider
+	MaxRetry     int
+	TimeOut      int
+	HedgeAfterMs int
+	MaxHedges    int
 }
 
 func ProvidersBymodelsName(ctx context.Context, modelsName string) (*ProvidersWithlimit, error) {
@@ -368,8 +521,42 @@ func ProvidersBymodelsName(ctx context.Context, modelsName string) (*ProvidersWi
 	)
 
 	return &ProvidersWithlimit{
-		Providers: llmproviders,
-		MaxRetry:  llmmodels.MaxRetry,
-		TimeOut:   llmmodels.TimeOut,
+		Providers:    llmproviders,
+		MaxRetry:     llmmodels.MaxRetry,
+		TimeOut:      llmmodels.TimeOut,
+		HedgeAfterMs: llmmodels.HedgeAfterMs,
+		MaxHedges:    llmmodels.MaxHedges,
 	}, nil
 }
+
+// allowsCapability 判断某项能力(工具调用/结构化输出/视觉)是否允许通过过滤；
+// 手动配置(configured非nil)的开关优先生效，否则回退到适配器自报的能力(reported)
+func allowsCapability(configured *bool, reported bool, requested bool) bool {
+	if !requested {
+		return true
+	}
+	if configured != nil {
+		return *configured
+	}
+	return reported
+}
+
+// tpmLimit 从请求上下文中读取当前API Key的TPM配额，供metering事后核销使用
+func tpmLimit(c *gin.Context) int {
+	if v, exists := c.Get(middleware.TPMLimitKey); exists {
+		if limit, ok := v.(int); ok {
+			return limit
+		}
+	}
+	return 0
+}
+
+// dailyTokenLimit 从请求上下文中读取当前API Key的每日token配额，供metering事后核销使用
+func dailyTokenLimit(c *gin.Context) int64 {
+	if v, exists := c.Get(middleware.DailyTokenLimitKey); exists {
+		if limit, ok := v.(int64); ok {
+			return limit
+		}
+	}
+	return 0
+}
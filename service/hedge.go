@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/atopos31/llmio/balancer"
+	"github.com/atopos31/llmio/models"
+	"github.com/atopos31/llmio/providers"
+)
+
+// hedgeJitterRatio 是HedgeAfterMs之上额外附加的随机抖动比例，避免同一时刻大量请求
+// 同时触发对冲，形成雷鸣群体效应
+const hedgeJitterRatio = 0.2
+
+// attemptOutcome 是一次候选provider请求的结果，selectHedgedWinner据此判断胜负
+type attemptOutcome struct {
+	id                int
+	modelWithProvider models.ModelWithProvider
+	provider          *models.Provider
+	res               *http.Response
+	err               error
+	reqStart          time.Time
+}
+
+// peekedBody 把已经读出的首字节拼回响应体前面，让下游消费者看到完整、未被截断的流
+type peekedBody struct {
+	peeked []byte
+	offset int
+	rest   io.ReadCloser
+}
+
+func (p *peekedBody) Read(b []byte) (int, error) {
+	if p.offset < len(p.peeked) {
+		n := copy(b, p.peeked[p.offset:])
+		p.offset += n
+		return n, nil
+	}
+	return p.rest.Read(b)
+}
+
+func (p *peekedBody) Close() error {
+	return p.rest.Close()
+}
+
+// jitter 给d附加一个[0, hedgeJitterRatio*d]的随机抖动
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d + time.Duration(rand.Int64N(int64(float64(d)*hedgeJitterRatio)+1))
+}
+
+// raceAttempt 发起一次候选provider请求；2xx响应会阻塞到真正读出首字节(或请求被取消)才上报，
+// 这样调度方只会在候选确实开始产出数据时才把它视为潜在赢家
+func raceAttempt(ctx context.Context, id int, style string, client *http.Client, modelWithProvider models.ModelWithProvider, provider *models.Provider, raw []byte, out chan<- attemptOutcome) {
+	reqStart := time.Now()
+
+	// HalfOpen探测名额只在真正下发请求的这一刻获取；拿不到许可就当作这个候选落败，
+	// 不计入熔断器失败统计(provider留空)，换下一个候选即可
+	if !balancer.Default.Acquire(provider.ID) {
+		out <- attemptOutcome{id: id, modelWithProvider: modelWithProvider, err: errors.New("circuit breaker: no permit available"), reqStart: reqStart}
+		return
+	}
+
+	chatModel, err := providers.New(style, provider.Config)
+	if err != nil {
+		out <- attemptOutcome{id: id, modelWithProvider: modelWithProvider, provider: provider, err: err, reqStart: reqStart}
+		return
+	}
+
+	res, err := chatModel.Chat(ctx, client, modelWithProvider.ProviderModel, raw)
+	if err != nil {
+		out <- attemptOutcome{id: id, modelWithProvider: modelWithProvider, provider: provider, err: err, reqStart: reqStart}
+		return
+	}
+
+	if res.StatusCode == http.StatusOK {
+		peek := make([]byte, 1)
+		n, rerr := res.Body.Read(peek)
+		if n == 0 {
+			res.Body.Close()
+			if rerr == nil {
+				rerr = errors.New("empty response body")
+			}
+			out <- attemptOutcome{id: id, modelWithProvider: modelWithProvider, provider: provider, err: rerr, reqStart: reqStart}
+			return
+		}
+		res.Body = &peekedBody{peeked: peek[:n], rest: res.Body}
+	}
+
+	out <- attemptOutcome{id: id, modelWithProvider: modelWithProvider, provider: provider, res: res, reqStart: reqStart}
+}
+
+// attemptLog 基于baseLog构造一条归属到具体候选provider的日志；baseLog本身要等
+// 最终胜出者确定后才会回填ProviderName/ProviderModel，失败/取消的候选必须在这里
+// 自己带上这些字段，否则对应的ChatLog行会找不到是哪个provider的问题
+func attemptLog(base models.ChatLog, o attemptOutcome) models.ChatLog {
+	log := base
+	if o.provider != nil {
+		log.ProviderName = o.provider.Name
+		log.ProviderModel = o.modelWithProvider.ProviderModel
+	}
+	return log
+}
+
+// recordCircuitOutcome 把一次候选请求的最终结果计入熔断器统计，同时释放它在raceAttempt里
+// 通过Acquire拿到的HalfOpen探测名额：2xx记一次成功；429代表限流而非provider故障，
+// 不应该触发熔断(与chat.go非对冲路径的处理保持一致)，只归还探测名额；其余情况记一次失败。
+// o.provider为nil(即没能acquire到许可)时没有名额可释放，直接返回，不做任何记录
+func recordCircuitOutcome(o attemptOutcome) {
+	if o.provider == nil {
+		return
+	}
+	switch {
+	case o.err == nil && o.res != nil && o.res.StatusCode == http.StatusOK:
+		balancer.Default.RecordSuccess(o.provider.ID, time.Since(o.reqStart))
+	case o.err == nil && o.res != nil && o.res.StatusCode == http.StatusTooManyRequests:
+		balancer.Default.ReleaseProbe(o.provider.ID)
+	default:
+		balancer.Default.RecordFailure(o.provider.ID)
+	}
+}
+
+// recordHedgeFailure 把一次落败的候选请求计入熔断器统计并写入重试日志，返回对应的错误。
+// 429响应按chat.go非对冲路径的做法处理：不计入熔断失败，而是把候选放回items降权重试，
+// 让它还有机会在后续的加权随机里被选中，而不是像其他失败那样被彻底判死
+func recordHedgeFailure(o attemptOutcome, items map[uint]int, retryErrLog chan<- models.ChatLog, baseLog models.ChatLog) error {
+	log := attemptLog(baseLog, o)
+	recordCircuitOutcome(o)
+	if o.err != nil {
+		retryErrLog <- log.WithError(o.err)
+		return o.err
+	}
+	if o.res.StatusCode == http.StatusTooManyRequests {
+		slog.Warn("hedge_rate_limit_hit", "provider", o.provider.Name)
+		weight := items[o.modelWithProvider.ID] - items[o.modelWithProvider.ID]/3
+		if weight <= 0 {
+			weight = 1
+		}
+		items[o.modelWithProvider.ID] = weight
+	}
+	byteBody, readErr := io.ReadAll(o.res.Body)
+	if readErr != nil {
+		slog.Error("read body error", "error", readErr)
+	}
+	o.res.Body.Close()
+	err := fmt.Errorf("status: %d, body: %s", o.res.StatusCode, string(byteBody))
+	retryErrLog <- log.WithError(err)
+	return err
+}
+
+// discardLoser 排干并关闭一个在胜者已确定之后才返回的候选请求的响应体，记录一条
+// 归属到该候选provider的hedged_cancelled重试日志，并释放它在熔断器里占用的名额——
+// 因被取消而返回context.Canceled的候选既不算成功也不算失败，只通过ReleaseProbe归还
+// 探测名额；其余结果经recordCircuitOutcome计入统计。否则这个名额永远不会被归还，
+// HalfOpen的唯一探测名额就会被永久占用，provider再也无法自愈
+func discardLoser(o attemptOutcome, retryErrLog chan<- models.ChatLog, baseLog models.ChatLog) {
+	if o.provider != nil && errors.Is(o.err, context.Canceled) {
+		balancer.Default.ReleaseProbe(o.provider.ID)
+	} else {
+		recordCircuitOutcome(o)
+	}
+	if o.res != nil {
+		io.Copy(io.Discard, o.res.Body)
+		o.res.Body.Close()
+	}
+	retryErrLog <- attemptLog(baseLog, o).WithError(errors.New("hedged_cancelled"))
+}
+
+// selectHedgedWinner 从items中挑选首个候选provider发起请求；若HedgeAfterMs内仍未拿到首字节，
+// 再挑选下一个候选并行起跑，最多同时存在1+maxHedges个在途请求。所有候选共享同一个结果
+// channel，由本函数里唯一的一个循环充当调度方：第一个返回2xx且拿到首字节的候选胜出后，
+// 仍在途的候选被取消，它们随后送达的结果在同一个循环里被当作loser排干/关闭、记录
+// hedged_cancelled，不再需要额外的goroutine去竞争读取——避免出现两个消费者抢同一个
+// 结果导致的goroutine泄漏和响应体未关闭。若所有候选都失败，返回最后一次的错误
+func selectHedgedWinner(
+	ctx context.Context,
+	requestID string,
+	style string,
+	client *http.Client,
+	items map[uint]int,
+	llmproviders []models.ModelWithProvider,
+	providerMap map[uint]*models.Provider,
+	raw []byte,
+	hedgeAfter time.Duration,
+	maxHedges int,
+	retryErrLog chan<- models.ChatLog,
+	baseLog models.ChatLog,
+) (attemptOutcome, error) {
+	cancels := make(map[int]context.CancelFunc)
+	outcomeCh := make(chan attemptOutcome, maxHedges+1)
+	lastErr := errors.New("no provider available for hedged attempt")
+	inFlight := 0
+
+	launch := func() bool {
+		item, err := balancer.WeightedRandom(items)
+		if err != nil {
+			return false
+		}
+		modelWithProviderIndex := slices.IndexFunc(llmproviders, func(mp models.ModelWithProvider) bool {
+			return mp.ID == *item
+		})
+		modelWithProvider := llmproviders[modelWithProviderIndex]
+		provider := providerMap[modelWithProvider.ProviderID]
+		delete(items, *item)
+
+		id := len(cancels)
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels[id] = cancel
+		inFlight++
+
+		go raceAttempt(attemptCtx, id, style, client, modelWithProvider, provider, raw, outcomeCh)
+		return true
+	}
+
+	if !launch() {
+		return attemptOutcome{}, lastErr
+	}
+
+	hedgesLaunched := 0
+	timer := time.NewTimer(jitter(hedgeAfter))
+	defer timer.Stop()
+
+	var winner *attemptOutcome
+
+	for inFlight > 0 {
+		select {
+		case <-ctx.Done():
+			for _, cancel := range cancels {
+				cancel()
+			}
+			// ctx取消时仍有候选在途：和胜者确定后的排干方式一样，逐个读完它们的
+			// 结果、关闭响应体、释放熔断器名额，而不是直接返回——否则这些候选的
+			// 响应体和HalfOpen探测名额会一直悬空，直到对应的goroutine退出
+			for inFlight > 0 {
+				o := <-outcomeCh
+				inFlight--
+				discardLoser(o, retryErrLog, baseLog)
+			}
+			if winner != nil {
+				return *winner, nil
+			}
+			return attemptOutcome{}, ctx.Err()
+
+		case o := <-outcomeCh:
+			inFlight--
+			delete(cancels, o.id)
+
+			switch {
+			case winner != nil:
+				// 胜者已确定，这是一个被取消的候选姗姗来迟的结果
+				discardLoser(o, retryErrLog, baseLog)
+			case o.err == nil && o.res != nil && o.res.StatusCode == http.StatusOK:
+				winner = &o
+				for _, cancel := range cancels {
+					cancel()
+				}
+			default:
+				lastErr = recordHedgeFailure(o, items, retryErrLog, baseLog)
+			}
+
+		case <-timer.C:
+			if winner == nil && hedgesLaunched < maxHedges && len(items) > 0 {
+				hedgesLaunched++
+				slog.Info("hedge_triggered",
+					"request_id", requestID,
+					"hedge_index", hedgesLaunched,
+				)
+				launch()
+			}
+			timer.Reset(jitter(hedgeAfter))
+		}
+	}
+
+	if winner != nil {
+		return *winner, nil
+	}
+	return attemptOutcome{}, lastErr
+}
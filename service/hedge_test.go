@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/atopos31/llmio/models"
+	"github.com/atopos31/llmio/providers"
+)
+
+// hedgeFixture描述一个测试候选provider该如何响应：等待多久、返回什么状态码/错误，
+// 让selectHedgedWinner的竞速、对冲与取消排干路径可以被确定性地驱动
+type hedgeFixture struct {
+	delay      time.Duration
+	delayFn    func() time.Duration // 优先于delay；用于让延迟依赖调用顺序而不是固定值
+	statusCode int
+	err        error
+	body       *trackingBody
+}
+
+// trackingBody记录响应体是否被Close过，用于断言discardLoser/ctx取消排干
+// 真的关闭了落败候选的响应体，而不是让它们悬空
+type trackingBody struct {
+	mu     sync.Mutex
+	reader *strings.Reader
+	closed bool
+}
+
+func newTrackingBody(content string) *trackingBody {
+	return &trackingBody{reader: strings.NewReader(content)}
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+func (b *trackingBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
+
+func (b *trackingBody) wasClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+var hedgeFixtures sync.Map // config string -> *hedgeFixture
+
+const hedgeTestStyle = "hedge-test-fixture"
+
+func init() {
+	providers.Register(hedgeTestStyle, func(config string) (providers.ChatModel, error) {
+		return &hedgeFixtureModel{config: config}, nil
+	})
+}
+
+type hedgeFixtureModel struct {
+	config string
+}
+
+func (m *hedgeFixtureModel) Chat(ctx context.Context, client *http.Client, model string, raw []byte) (*http.Response, error) {
+	v, ok := hedgeFixtures.Load(m.config)
+	if !ok {
+		return nil, fmt.Errorf("no hedge fixture registered for %q", m.config)
+	}
+	f := v.(*hedgeFixture)
+	delay := f.delay
+	if f.delayFn != nil {
+		delay = f.delayFn()
+	}
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &http.Response{StatusCode: f.statusCode, Body: f.body}, nil
+}
+
+func newHedgeCandidate(t *testing.T, providerID uint, f hedgeFixture) (models.ModelWithProvider, *models.Provider) {
+	t.Helper()
+	config := fmt.Sprintf("%s-%d", t.Name(), providerID)
+	hedgeFixtures.Store(config, &f)
+	t.Cleanup(func() { hedgeFixtures.Delete(config) })
+
+	provider := &models.Provider{ID: providerID, Name: config, Type: hedgeTestStyle, Config: config}
+	mwp := models.ModelWithProvider{ID: providerID, ProviderID: providerID, ProviderModel: "fixture-model"}
+	return mwp, provider
+}
+
+func TestSelectHedgedWinnerReturnsOnlyCandidate(t *testing.T) {
+	body := newTrackingBody("hello")
+	mwp, provider := newHedgeCandidate(t, 101, hedgeFixture{statusCode: http.StatusOK, body: body})
+
+	items := map[uint]int{mwp.ID: 1}
+	providerMap := map[uint]*models.Provider{provider.ID: provider}
+	retryErrLog := make(chan models.ChatLog, 4)
+
+	outcome, err := selectHedgedWinner(context.Background(), "req-1", hedgeTestStyle, providers.GetClient(time.Second),
+		items, []models.ModelWithProvider{mwp}, providerMap, []byte("{}"), 50*time.Millisecond, 1, retryErrLog, models.ChatLog{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outcome.provider.ID != provider.ID {
+		t.Fatalf("expected winner provider %d, got %d", provider.ID, outcome.provider.ID)
+	}
+	if body.wasClosed() {
+		t.Fatal("winner's response body must stay open for the caller to read")
+	}
+}
+
+func TestSelectHedgedWinnerReturnsFasterHedgedCandidate(t *testing.T) {
+	// 加权随机选择哪个候选先起跑是不确定的，所以延迟按"第几个被调用"而不是
+	// provider身份来决定：先起跑的那个故意很慢，hedge触发后起跑的第二个立刻成功，
+	// 这样无论两个候选谁先被选中，断言都成立
+	var launchOrder int32
+	delayFn := func() time.Duration {
+		if atomic.AddInt32(&launchOrder, 1) == 1 {
+			return 300 * time.Millisecond
+		}
+		return 0
+	}
+
+	bodyA := newTrackingBody("a")
+	bodyB := newTrackingBody("b")
+	mwpA, providerA := newHedgeCandidate(t, 201, hedgeFixture{delayFn: delayFn, statusCode: http.StatusOK, body: bodyA})
+	mwpB, providerB := newHedgeCandidate(t, 202, hedgeFixture{delayFn: delayFn, statusCode: http.StatusOK, body: bodyB})
+
+	items := map[uint]int{mwpA.ID: 1, mwpB.ID: 1}
+	llmproviders := []models.ModelWithProvider{mwpA, mwpB}
+	providerMap := map[uint]*models.Provider{providerA.ID: providerA, providerB.ID: providerB}
+	retryErrLog := make(chan models.ChatLog, 4)
+
+	start := time.Now()
+	outcome, err := selectHedgedWinner(context.Background(), "req-2", hedgeTestStyle, providers.GetClient(time.Second),
+		items, llmproviders, providerMap, []byte("{}"), 10*time.Millisecond, 1, retryErrLog, models.ChatLog{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 必须是由hedge触发的那个即时候选胜出，而不是等满300ms让先起跑的慢候选收尾
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Fatalf("expected the hedged instant candidate to win well before the slow one, took %s", elapsed)
+	}
+	if outcome.provider.ID != providerA.ID && outcome.provider.ID != providerB.ID {
+		t.Fatalf("unexpected winner provider %d", outcome.provider.ID)
+	}
+}
+
+// discardLoser把一次被丢弃的候选计入熔断器统计并关闭响应体，单独直接驱动它，
+// 避开竞速本身的时序不确定性；熔断器名额释放的具体语义由balancer包自己的测试覆盖
+func TestDiscardLoserClosesResponseBody(t *testing.T) {
+	body := newTrackingBody("late loser")
+	mwp, provider := newHedgeCandidate(t, 501, hedgeFixture{statusCode: http.StatusOK, body: body})
+
+	retryErrLog := make(chan models.ChatLog, 1)
+	outcome := attemptOutcome{modelWithProvider: mwp, provider: provider, res: &http.Response{StatusCode: http.StatusOK, Body: body}, reqStart: time.Now()}
+
+	discardLoser(outcome, retryErrLog, models.ChatLog{})
+
+	if !body.wasClosed() {
+		t.Fatal("expected discardLoser to close the discarded candidate's response body")
+	}
+	select {
+	case <-retryErrLog:
+	default:
+		t.Fatal("expected discardLoser to log the discarded candidate")
+	}
+}
+
+func TestSelectHedgedWinnerDrainsOnContextCancellation(t *testing.T) {
+	body := newTrackingBody("never read")
+	mwp, provider := newHedgeCandidate(t, 301, hedgeFixture{delay: time.Second, statusCode: http.StatusOK, body: body})
+
+	items := map[uint]int{mwp.ID: 1}
+	providerMap := map[uint]*models.Provider{provider.ID: provider}
+	retryErrLog := make(chan models.ChatLog, 4)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// 候选的Chat()还阻塞在1秒延迟里，ctx先一步超时：selectHedgedWinner必须排干这个
+	// 仍在途的候选并拿到它因ctx结束而返回的错误结果后才返回，而不是直接返回让它悬空
+	start := time.Now()
+	_, err := selectHedgedWinner(ctx, "req-3", hedgeTestStyle, providers.GetClient(time.Second),
+		items, []models.ModelWithProvider{mwp}, providerMap, []byte("{}"), time.Second, 1, retryErrLog, models.ChatLog{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected selectHedgedWinner to return promptly after ctx cancellation, took %s", elapsed)
+	}
+
+	select {
+	case <-retryErrLog:
+	default:
+		t.Fatal("expected the drained in-flight candidate to be recorded before returning")
+	}
+}
+
+func TestRecordHedgeFailureOnRateLimitReducesWeightInstead(t *testing.T) {
+	body := newTrackingBody("rate limited")
+	mwp, provider := newHedgeCandidate(t, 401, hedgeFixture{statusCode: http.StatusTooManyRequests, body: body})
+
+	items := map[uint]int{mwp.ID: 9}
+	retryErrLog := make(chan models.ChatLog, 1)
+
+	outcome := attemptOutcome{modelWithProvider: mwp, provider: provider, res: &http.Response{StatusCode: http.StatusTooManyRequests, Body: body}, reqStart: time.Now()}
+	err := recordHedgeFailure(outcome, items, retryErrLog, models.ChatLog{})
+	if err == nil {
+		t.Fatal("expected an error describing the rate-limited response")
+	}
+	if items[mwp.ID] >= 9 {
+		t.Fatalf("expected 429 to reduce the candidate's weight, got %d", items[mwp.ID])
+	}
+	if !body.wasClosed() {
+		t.Fatal("expected the rate-limited response body to be closed")
+	}
+}